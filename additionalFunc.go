@@ -4,9 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"github.com/SevereCloud/vksdk/v2/api"
-	"github.com/SevereCloud/vksdk/v2/api/params"
 	"github.com/stephenafamo/kronika"
+	"log/slog"
+	"runtime/debug"
 	"time"
 )
 
@@ -18,6 +18,15 @@ func isSunday(date time.Time) bool {
 	}
 }
 
+func getWeekStart(date time.Time) time.Time {
+
+	// Функция getWeekStart() возвращает понедельник недели, к которой относится дата.
+	// Воскресенье считается последним днем недели.
+
+	offset := (int(date.Weekday()) + 6) % 7
+	return date.AddDate(0, 0, -offset)
+}
+
 func getRuWeekDay(date time.Time) string {
 	switch date.Weekday() {
 	case 1:
@@ -32,124 +41,152 @@ func getRuWeekDay(date time.Time) string {
 		return "Пятница"
 	case 6:
 		return "Суббота"
-	case 7:
+	case 0:
 		return "Воскресенье"
 	}
 	return ""
 }
 
-func sendUpdMessage(db *sql.DB, vk *api.VK, message string) string {
+func cronSending(cfg *Config, db *sql.DB, ms messengers, schedule *ScheduleService) {
+
+	// Функция cronSending() отвечает за запланированную отправку расписания.
+	// Каждый чат получает расписание в настроенное командой /notify время, а без настроек - утром на сегодня
+	// и вечером на завтра (по умолчанию в 8:00 и 20:00, время задается в настройках бота).
+	// Время считается в часовом поясе бота, независимо от часового пояса сервера.
+	// Используется пакет kronika (github.com/stephenafamo/kronika).
+
+	// Благодаря context.Background(), функция не имеет ни дедлайнов, ни переменных, и выполняется в бэкграунде.
+	ctx := context.Background()
+
+	// kronika-действие выполняется в начале каждой минуты и отправляет расписание во все чаты, которым оно запланировано на эту минуту.
+	start := time.Now().Truncate(time.Minute)
+	interval := time.Minute
 
-	// Функция для рассылки произвольного сообщения по всем беседам, в которых были созданы ассоциации.
+	// Начало kronika-действия. В kronika.Every() передается контекст, интервал и время начала.
+	for now := range kronika.Every(ctx, start, interval) {
+		sendDueSchedules(cfg, db, ms, schedule, now)
+	}
+}
 
-	var groupId = 0
-	var response = fmt.Sprintf("Сообщение: \n\"%s\"\n\nБыло отправлено в: ", message)
-	// Запрос к БД на получение всех ID ассоциированных чатов
-	rows, _ := db.Query("select groupID from binds")
+func sendDueSchedules(cfg *Config, db *sql.DB, ms messengers, schedule *ScheduleService, now time.Time) {
 
-	defer rows.Close()
-	for rows.Next() {
+	// Функция sendDueSchedules() отправляет расписание во все чаты, которым оно запланировано на минуту now.
+	// Минута переводится в часовой пояс бота, поэтому now может быть в любом часовом поясе.
 
-		// Получение ID бесед
-		rows.Scan(&groupId)
+	now = now.In(cfg.Location)
+	slot := now.Format("15:04")
 
-		println(groupId)
+	deliveries, err := getDueDeliveries(db, cfg, slot)
+	if err != nil {
+		slog.Error("cron: get due deliveries", "slot", slot, "err", err)
+		return
+	}
+	slog.Debug("cron: slot", "slot", slot, "deliveries", len(deliveries))
+
+	// Каждая отправка записывается в лог, чтобы можно было выяснить, почему чат не получил расписание.
+	for _, d := range deliveries {
+		started := time.Now()
+		err := deliverSchedule(cfg, ms, schedule, d, getScheduleView(db, d.chat), now)
+		logger := slog.With("chat", d.chat, "group", d.groupNumber, "day", d.day, "slot", slot, "latency", time.Since(started))
+		if err != nil {
+			cronDeliveriesTotal.WithLabelValues(slot, "error").Inc()
+			logger.Error("cron: delivery failed", "err", err)
+		} else {
+			cronDeliveriesTotal.WithLabelValues(slot, "ok").Inc()
+			logger.Info("cron: delivered")
+		}
+	}
 
-		// Отправка переданного в функцию сообщения в беседу с соответствующим groupId
-		b := params.NewMessagesSendBuilder()
-		b.RandomID(0)
-		b.PeerID(groupId)
-		b.Message(message)
-		vk.MessagesSend(b.Params)
-		response += fmt.Sprintf("%d", groupId)
+	// Напоминания об экзаменах отправляются вместе со стандартной рассылкой: вечером - о завтрашних, утром - о сегодняшних.
+	switch slot {
+	case cfg.EveningTime:
+		sendExamReminders(db, ms, schedule, now, 1)
+	case cfg.MorningTime:
+		sendExamReminders(db, ms, schedule, now, 0)
 	}
-	return response
 }
 
-func cronSending(db *sql.DB, vk *api.VK) {
+func deliverSchedule(cfg *Config, ms messengers, schedule *ScheduleService, d delivery, view scheduleView, now time.Time) (err error) {
 
-	// Функция cronSending() отвечает за запланированную отправку расписания утром (в 8:00) и вечером (в 20:00).
-	// Расписание отправляемое утром - на сегодняшний день, вечером - на завтрашний.
-	// Используется пакет kronika (github.com/stephenafamo/kronika).
+	// Функция deliverSchedule() отправляет в чат запланированное расписание:
+	// 	1. На сегодня - если сегодня воскресенье, то на понедельник;
+	// 	2. На завтра - если завтра воскресенье, то на понедельник,
+	// 	   а при включенной настройке - сразу на всю следующую неделю.
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v\n%s", p, debug.Stack())
+		}
+	}()
 
 	var message = ""
+	var date = now
 
-	// Благодаря context.Background(), функция не имеет ни дедлайнов, ни переменных, и выполняется в бэкграунде.
-	ctx := context.Background()
+	if d.day == NOTIFY_TOMORROW {
+		date = now.AddDate(0, 0, 1)
 
-	// В переменной start хранится время начала выполнения kronika-действия. 20:00
-	// Переменная interval указывает интервал выполнения действия. 12 часов
-	start, _ := time.Parse("2006-01-02 15:04:05", "2022-01-01 20:00:00")
-	interval := time.Hour * 12 //  8:00 am and 20:00 pm
+		if isSunday(now) && cfg.SundayWeekSchedule {
+			// В воскресенье вечером, вместо расписания на понедельник, отправляется расписание на всю следующую неделю.
+			monday := date
 
-	// Начало kronika-действия. В kronika.Every() передается контекст, интервал и время начала.
-	for range kronika.Every(ctx, start, interval) {
-
-		var groupId int
-		var groupNumber string
-		var date string
-
-		var today = time.Now()
-		var tommorow = time.Now().AddDate(0, 0, 1)
-
-		// В переменную rows записывается ответ, полученной об БД после запроса.
-		// Цель запроса - получить все данные обо всех ассоциациях
-		rows, _ := db.Query("select groupID, groupNumber from binds")
-
-		defer rows.Close()
-		for rows.Next() {
-
-			// На каждый полученный ответ выполняется следующие действия:
-			// 1. Ответ записывается в переменные groupID и groupNumber;
-			// 2. Определяется время выполнение скрипта:
-			// 	2.1 Если время 08:00, то отправляется расписание на сегодняшний день;
-			// 	2.2 Если время 20:00, то отправляется расписание на завтрашний день.
-			rows.Scan(&groupId, &groupNumber)
-
-			if time.Now().Hour() == 8 {
-
-				// Проверка на выходной день
-				if isSunday(today) {
-					// Если сегодня воскресенье, то к дате прибавляется один день
-					date = today.AddDate(0, 0, 1).Format("20060102")
-					message += "Сегодня воскресенье, но вот расписание на понедельник: \n"
-				} else {
-					// Иначе, используется сегодняшняя дата
-					date = today.Format("20060102")
+			lessons, err := schedule.LessonsBetween(d.groupNumber, monday, monday.AddDate(0, 0, 5))
+			if err != nil {
+				return err
+			}
+			for _, message := range formWeekMessages(d.groupNumber, monday, lessons, view) {
+				if err = ms.send(d.chat, message); err != nil {
+					return err
 				}
+			}
+			return nil
+		}
 
-				parseSchedule(groupNumber, date)
-				message = formMessage(groupNumber, date)
-
-				b := params.NewMessagesSendBuilder()
-				b.Message(message)
-				b.RandomID(0)
-				b.PeerID(groupId) // groupId
-				vk.MessagesSend(b.Params)
-
-			} else if time.Now().Hour() == 20 {
-
-				// Проверка на выходной день
-				if isSunday(tommorow) {
-					// Если завтра воскресенье, то к дате прибавляется два дня, вместо одного
-					date = tommorow.AddDate(0, 0, 1).Format("20060102")
-					message += "Завтра воскресенье, но вот расписание на понедельник: \n"
-				} else {
-					// Иначе, используется завтрашняя дата
-					date = tommorow.Format("20060102")
-				}
+		if isSunday(date) {
+			// Если завтра воскресенье, то к дате прибавляется два дня, вместо одного
+			date = date.AddDate(0, 0, 1)
+			message += exTommorowIsSunday
+		}
+	} else if isSunday(date) {
+		// Если сегодня воскресенье, то к дате прибавляется один день
+		date = date.AddDate(0, 0, 1)
+		message += exTodayIsSunday
+	}
+
+	lessons, err := schedule.LessonsFor(d.groupNumber, date)
+	if err != nil {
+		return err
+	}
+	return ms.send(d.chat, message+formMessage(d.groupNumber, date, lessons, view))
+}
+
+func levenshtein(a string, b string) int {
 
-				parseSchedule(groupNumber, date)
-				message = formMessage(groupNumber, date)
+	// Функция levenshtein() считает расстояние Левенштейна между строками: сколько символов нужно вставить,
+	// удалить или заменить, чтобы получить из одной строки другую. Используется для поиска с опечатками.
 
-				b := params.NewMessagesSendBuilder()
-				b.Message(message)
-				b.RandomID(0)
-				b.PeerID(groupId) // groupId
-				vk.MessagesSend(b.Params)
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
 			}
-			// Очистка сообщения после каждой итерации.
-			message = ""
 		}
+		prev, cur = cur, prev
 	}
+	return prev[len(rb)]
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Неделя с 09.01.2023 (понедельник): по одному занятию в понедельник, вторник и среду.
+func newTestWeekSchedule(t *testing.T) *ScheduleService {
+	return newTestSchedule(t, map[string]string{"431-2": testCalendarHeader +
+		testEvent("20230109T084500", "20230109T102000", "Математика", "Лекция\\, Иванов И.И.", "рк 101") +
+		testEvent("20230110T084500", "20230110T102000", "Физика", "Практика\\, Петров П.П.", "рк 202") +
+		testEvent("20230111T084500", "20230111T102000", "Химия", "Лекция\\, Сидоров С.С.", "рк 303") +
+		testCalendarFooter})
+}
+
+func TestDeliverSchedule(t *testing.T) {
+	schedule := newTestWeekSchedule(t)
+	chat := chatID{PLATFORM_VK, 1}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		day        string
+		sundayWeek bool
+		want       []string
+		skip       []string
+	}{
+		{"today", time.Date(2023, 1, 9, 8, 0, 0, 0, scheduleLocation), NOTIFY_TODAY, true,
+			[]string{"Математика"}, []string{"Физика", exTodayIsSunday}},
+		{"tomorrow", time.Date(2023, 1, 9, 20, 0, 0, 0, scheduleLocation), NOTIFY_TOMORROW, true,
+			[]string{"Физика"}, []string{"Математика"}},
+		{"sunday today", time.Date(2023, 1, 8, 8, 0, 0, 0, scheduleLocation), NOTIFY_TODAY, true,
+			[]string{exTodayIsSunday, "Математика"}, []string{"Физика"}},
+		{"saturday tomorrow", time.Date(2023, 1, 7, 20, 0, 0, 0, scheduleLocation), NOTIFY_TOMORROW, true,
+			[]string{exTommorowIsSunday, "Математика"}, []string{"Физика"}},
+		{"sunday next week", time.Date(2023, 1, 8, 20, 0, 0, 0, scheduleLocation), NOTIFY_TOMORROW, true,
+			[]string{"Математика", "Физика", "Химия"}, []string{exTommorowIsSunday}},
+		{"sunday next week off", time.Date(2023, 1, 8, 20, 0, 0, 0, scheduleLocation), NOTIFY_TOMORROW, false,
+			[]string{"Математика"}, []string{"Физика", exTommorowIsSunday}},
+	}
+
+	for _, tt := range tests {
+		ms := newFakeMessenger()
+		cfg := &Config{SundayWeekSchedule: tt.sundayWeek, Location: scheduleLocation}
+		d := delivery{chat: chat, groupNumber: "431-2", day: tt.day}
+
+		if err := deliverSchedule(cfg, messengers{PLATFORM_VK: ms}, schedule, d, scheduleView{}, tt.now); err != nil {
+			t.Errorf("%s: deliverSchedule() err = %v", tt.name, err)
+			continue
+		}
+		message := strings.Join(ms.messages(chat.ID), "\n")
+		for _, s := range tt.want {
+			if !strings.Contains(message, s) {
+				t.Errorf("%s: %q not in %q", tt.name, s, message)
+			}
+		}
+		for _, s := range tt.skip {
+			if strings.Contains(message, s) {
+				t.Errorf("%s: %q in %q", tt.name, s, message)
+			}
+		}
+	}
+}
+
+func TestSendDueSchedulesTimezone(t *testing.T) {
+
+	// Время рассылки сравнивается во времени бота: на сервере в UTC 00:00 - это 07:00 в Томске.
+
+	db := newTestDB(t)
+	ms := newFakeMessenger()
+	schedule := newTestWeekSchedule(t)
+	cfg := &Config{MorningTime: "08:00", EveningTime: "20:00", SundayWeekSchedule: true, Location: scheduleLocation}
+
+	standard := chatID{PLATFORM_VK, 1}
+	early := chatID{PLATFORM_VK, 2}
+	off := chatID{PLATFORM_VK, 3}
+	for _, chat := range []chatID{standard, early, off} {
+		if _, err := addBinding(db, chat, "431-2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := setNotifySlots(db, early, []notifySlot{{"07:00", NOTIFY_TODAY}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := resetNotifySlots(db, off, true); err != nil {
+		t.Fatal(err)
+	}
+
+	sendDueSchedules(cfg, db, messengers{PLATFORM_VK: ms}, schedule, time.Date(2023, 1, 9, 0, 0, 0, 0, time.UTC))
+	if sent := ms.messages(early.ID); len(sent) != 1 || !strings.Contains(sent[0], "Математика") {
+		t.Errorf("07:00: %s got %q", early, sent)
+	}
+	if sent := ms.messages(standard.ID); len(sent) != 0 {
+		t.Errorf("07:00: %s got %q, want nothing", standard, sent)
+	}
+
+	sendDueSchedules(cfg, db, messengers{PLATFORM_VK: ms}, schedule, time.Date(2023, 1, 9, 13, 0, 0, 0, time.UTC))
+	if sent := ms.messages(standard.ID); len(sent) != 1 || !strings.Contains(sent[0], "Физика") {
+		t.Errorf("20:00: %s got %q", standard, sent)
+	}
+	if sent := ms.messages(early.ID); len(sent) != 1 {
+		t.Errorf("20:00: %s got %d messages, want only the morning one", early, len(sent))
+	}
+	if sent := ms.messages(off.ID); len(sent) != 0 {
+		t.Errorf("%s got %q, want nothing", off, sent)
+	}
+}
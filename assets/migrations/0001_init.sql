@@ -0,0 +1,15 @@
+-- Исходная схема БД: ассоциации чатов с группами и время рассылки расписания.
+-- Таблицы могут уже существовать в БД, созданных до появления миграций.
+
+CREATE TABLE IF NOT EXISTS binds(
+   id INTEGER PRIMARY KEY AUTOINCREMENT,
+   groupId TEXT,
+   groupNumber TEXT
+);
+
+CREATE TABLE IF NOT EXISTS notifications(
+   chat TEXT,
+   time TEXT,
+   day TEXT,
+   UNIQUE(chat, time)
+);
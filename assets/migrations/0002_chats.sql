@@ -0,0 +1,53 @@
+-- Чаты хранятся парой "платформа - ID" вместо ключа вида "tg:123" в текстовом поле groupId.
+-- У чата может быть только одна ассоциация, поэтому повторы из старой таблицы отбрасываются, остается последняя.
+
+CREATE TABLE binds_new(
+   id INTEGER PRIMARY KEY AUTOINCREMENT,
+   platform TEXT NOT NULL DEFAULT 'vk',
+   peer_id INTEGER NOT NULL,
+   group_number TEXT NOT NULL,
+   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   UNIQUE(platform, peer_id)
+);
+
+INSERT INTO binds_new(platform, peer_id, group_number)
+SELECT
+   CASE WHEN instr(groupId, ':') > 0 THEN substr(groupId, 1, instr(groupId, ':') - 1) ELSE 'vk' END,
+   CAST(CASE WHEN instr(groupId, ':') > 0 THEN substr(groupId, instr(groupId, ':') + 1) ELSE groupId END AS INTEGER),
+   groupNumber
+FROM binds
+WHERE id IN (SELECT max(id) FROM binds GROUP BY groupId) AND groupNumber IS NOT NULL;
+
+DROP TABLE binds;
+ALTER TABLE binds_new RENAME TO binds;
+
+CREATE TABLE notifications_new(
+   platform TEXT NOT NULL DEFAULT 'vk',
+   peer_id INTEGER NOT NULL,
+   time TEXT NOT NULL,
+   day TEXT NOT NULL,
+   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   UNIQUE(platform, peer_id, time)
+);
+
+INSERT INTO notifications_new(platform, peer_id, time, day)
+SELECT
+   CASE WHEN instr(chat, ':') > 0 THEN substr(chat, 1, instr(chat, ':') - 1) ELSE 'vk' END,
+   CAST(CASE WHEN instr(chat, ':') > 0 THEN substr(chat, instr(chat, ':') + 1) ELSE chat END AS INTEGER),
+   time,
+   day
+FROM notifications;
+
+DROP TABLE notifications;
+ALTER TABLE notifications_new RENAME TO notifications;
+
+-- Настройки чатов в виде "название - значение", чтобы новые настройки не требовали изменения схемы.
+CREATE TABLE chat_settings(
+   platform TEXT NOT NULL,
+   peer_id INTEGER NOT NULL,
+   name TEXT NOT NULL,
+   value TEXT NOT NULL,
+   updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   PRIMARY KEY(platform, peer_id, name)
+);
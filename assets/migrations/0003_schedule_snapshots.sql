@@ -0,0 +1,7 @@
+-- Последняя просмотренная версия расписания каждой группы, с которой сравнивается новая для поиска изменений.
+
+CREATE TABLE schedule_snapshots(
+   group_number TEXT PRIMARY KEY,
+   lessons TEXT NOT NULL,
+   updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
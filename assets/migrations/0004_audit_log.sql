@@ -0,0 +1,11 @@
+-- Журнал служебных команд (/db, /upd и т.п.): кто, когда, с какими аргументами и с каким результатом их вызывал.
+
+CREATE TABLE audit_log(
+   id INTEGER PRIMARY KEY AUTOINCREMENT,
+   platform TEXT NOT NULL,
+   peer_id INTEGER NOT NULL,
+   command TEXT NOT NULL,
+   args TEXT NOT NULL DEFAULT '',
+   outcome TEXT NOT NULL,
+   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
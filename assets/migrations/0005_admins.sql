@@ -0,0 +1,10 @@
+-- Администраторы бота. Владелец из настроек (admin_id, telegram_admin_id) в таблицу не записывается и всегда имеет роль owner.
+
+CREATE TABLE admins(
+   platform TEXT NOT NULL,
+   user_id INTEGER NOT NULL,
+   role TEXT NOT NULL,
+   granted_by TEXT NOT NULL DEFAULT '',
+   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   PRIMARY KEY (platform, user_id)
+);
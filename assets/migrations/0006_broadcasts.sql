@@ -0,0 +1,18 @@
+-- Рассылки /upd. Рассылка создается черновиком, после подтверждения ставится в очередь,
+-- а по окончании в ней сохраняется число доставленных и недоставленных сообщений.
+
+CREATE TABLE broadcasts(
+   id INTEGER PRIMARY KEY AUTOINCREMENT,
+   platform TEXT NOT NULL,
+   peer_id INTEGER NOT NULL,
+   user_id INTEGER NOT NULL,
+   target_kind TEXT NOT NULL,
+   target TEXT NOT NULL DEFAULT '',
+   text TEXT NOT NULL,
+   status TEXT NOT NULL,
+   delivered INTEGER NOT NULL DEFAULT 0,
+   failed INTEGER NOT NULL DEFAULT 0,
+   blocked INTEGER NOT NULL DEFAULT 0,
+   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
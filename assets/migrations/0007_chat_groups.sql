@@ -0,0 +1,18 @@
+-- Чат может получать расписание нескольких групп: потоковые беседы, студенты, следящие за соседней группой.
+-- Уникальной теперь должна быть пара "чат - группа", а ограничение UNIQUE в SQLite не изменить без пересоздания таблицы.
+
+CREATE TABLE binds_new(
+   id INTEGER PRIMARY KEY AUTOINCREMENT,
+   platform TEXT NOT NULL DEFAULT 'vk',
+   peer_id INTEGER NOT NULL,
+   group_number TEXT NOT NULL,
+   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   UNIQUE(platform, peer_id, group_number)
+);
+
+INSERT INTO binds_new(id, platform, peer_id, group_number, created_at, updated_at)
+SELECT id, platform, peer_id, group_number, created_at, updated_at FROM binds;
+
+DROP TABLE binds;
+ALTER TABLE binds_new RENAME TO binds;
@@ -0,0 +1,13 @@
+-- Фильтры занятий чатов. Правило скрывает (hide) или, в качестве исключения, оставляет (show) занятия,
+-- у которых поле field (subject, kind или teacher) содержит pattern.
+
+CREATE TABLE lesson_filters(
+   id INTEGER PRIMARY KEY AUTOINCREMENT,
+   platform TEXT NOT NULL,
+   peer_id INTEGER NOT NULL,
+   action TEXT NOT NULL,
+   field TEXT NOT NULL,
+   pattern TEXT NOT NULL,
+   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   UNIQUE(platform, peer_id, action, field, pattern)
+);
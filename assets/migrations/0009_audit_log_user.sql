@@ -0,0 +1,4 @@
+-- Автор служебной команды в журнале действий. Платформа пользователя совпадает с платформой чата,
+-- поэтому хранится только его ID. У записей, сделанных до появления столбца, ID автора неизвестен и равен 0.
+
+ALTER TABLE audit_log ADD COLUMN user_id INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Сколько сообщений рассылки отправляется в секунду. ВК разрешает сообществу не больше 20 запросов в секунду,
+// и часть из них нужна, чтобы во время рассылки бот продолжал отвечать на команды.
+const BROADCAST_RATE = 15
+
+// Сколько раз бот пытается отправить сообщение рассылки в чат и через сколько повторяет попытку после первой неудачи.
+// Каждая следующая пауза вдвое длиннее предыдущей.
+const BROADCAST_ATTEMPTS = 3
+const BROADCAST_RETRY_DELAY = 2 * time.Second
+
+// Получатели рассылки: все чаты с ассоциациями, чаты групп одного факультета или чаты перечисленных групп.
+const TARGET_ALL = "all"
+const TARGET_FACULTY = "faculty"
+const TARGET_GROUPS = "groups"
+
+type broadcastTarget struct {
+	kind  string
+	value string // Факультет или номера групп через запятую.
+}
+
+func (t broadcastTarget) String() string {
+	switch t.kind {
+	case TARGET_FACULTY:
+		return "факультет " + t.value
+	case TARGET_GROUPS:
+		return "группы " + strings.ReplaceAll(t.value, ",", ", ")
+	}
+	return "все чаты"
+}
+
+// Рассылка, созданная командой /upd.
+type broadcast struct {
+	id     int64
+	chat   chatID // Чат, из которого создана рассылка. В него отправляется отчет.
+	user   chatID // Автор рассылки.
+	target broadcastTarget
+	text   string
+	status string
+
+	delivered int
+	failed    int
+	blocked   int // Чаты, в которые бот больше не может писать: его заблокировали или удалили из беседы.
+}
+
+// broadcaster отправляет подтвержденные рассылки по одной, не быстрее BROADCAST_RATE сообщений в секунду.
+type broadcaster struct {
+	db       *sql.DB
+	ms       messengers
+	schedule *ScheduleService
+	queue    chan int64
+	throttle *time.Ticker
+}
+
+func newBroadcaster(db *sql.DB, ms messengers, schedule *ScheduleService) *broadcaster {
+	return &broadcaster{
+		db:       db,
+		ms:       ms,
+		schedule: schedule,
+		queue:    make(chan int64, 100),
+		throttle: time.NewTicker(time.Second / BROADCAST_RATE),
+	}
+}
+
+func (b *broadcaster) run() {
+
+	// Функция run() отправляет рассылки из очереди. Рассылки, подтвержденные до перезапуска бота, отправляются сразу,
+	// а прерванные перезапуском не отправляются повторно, чтобы чаты не получили одно сообщение дважды.
+
+	queued, err := interruptBroadcasts(b.db)
+	if err != nil {
+		slog.Error("broadcast: restore queue", "err", err)
+	}
+	for _, id := range queued {
+		b.deliver(id)
+	}
+
+	for id := range b.queue {
+		b.deliver(id)
+	}
+}
+
+func (b *broadcaster) enqueue(id int64) bool {
+
+	// Функция enqueue() ставит рассылку в очередь, не дожидаясь места в ней: обработчик команды не должен зависать,
+	// пока отправляются предыдущие рассылки. Если очередь заполнена, возвращается отрицательный результат.
+
+	select {
+	case b.queue <- id:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *broadcaster) recipients(target broadcastTarget) ([]chatID, error) {
+
+	// Функция recipients() возвращает чаты, которым адресована рассылка, в порядке платформ и ID.
+
+	bindings, err := getBindings(b.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups = make(map[string]bool)
+	for _, g := range strings.Split(target.value, ",") {
+		groups[normalizeGroup(g)] = true
+	}
+
+	var chats []chatID
+	for chat, chatGroups := range bindings {
+		for _, g := range chatGroups {
+			if target.kind == TARGET_ALL ||
+				target.kind == TARGET_FACULTY && b.schedule.GroupFaculty(g) == target.value ||
+				target.kind == TARGET_GROUPS && groups[normalizeGroup(g)] {
+				chats = append(chats, chat)
+				break
+			}
+		}
+	}
+
+	sort.Slice(chats, func(i, j int) bool {
+		if chats[i].Platform != chats[j].Platform {
+			return chats[i].Platform < chats[j].Platform
+		}
+		return chats[i].ID < chats[j].ID
+	})
+	return chats, nil
+}
+
+func (b *broadcaster) deliver(id int64) {
+
+	// Функция deliver() отправляет рассылку всем получателям и сообщает автору, сколько сообщений дошло.
+	// Рассылка отправляется, только если она все еще ожидает отправки: отмененная после подтверждения рассылка пропускается.
+
+	defer recoverPanic("broadcast")
+
+	ok, err := setBroadcastStatus(b.db, id, BROADCAST_QUEUED, BROADCAST_SENDING)
+	if err != nil || !ok {
+		if err != nil {
+			slog.Error("broadcast: start", "id", id, "err", err)
+		}
+		return
+	}
+
+	// Если рассылку не удалось начать, она помечается неудавшейся, а не остается отправляющейся навсегда.
+	bc, _, err := getBroadcast(b.db, id)
+	if err != nil {
+		slog.Error("broadcast: load", "id", id, "err", err)
+		b.fail(id)
+		return
+	}
+	chats, err := b.recipients(bc.target)
+	if err != nil {
+		slog.Error("broadcast: recipients", "id", id, "err", err)
+		b.fail(id)
+		if err = b.ms.send(bc.chat, fmt.Sprintf(broadcastNotStartedMsg, id)); err != nil {
+			slog.Error("broadcast: send report", "id", id, "chat", bc.chat, "err", err)
+		}
+		return
+	}
+
+	slog.Info("broadcast: started", "id", id, "target", bc.target.String(), "recipients", len(chats))
+	started := time.Now()
+
+	var failed, blocked []string
+	for _, chat := range chats {
+		err := b.sendWithRetry(chat, bc.text)
+		switch {
+		case err == nil:
+			bc.delivered++
+			continue
+		case errors.Is(err, errChatBlocked):
+			bc.blocked++
+			blocked = append(blocked, chat.String())
+		default:
+			bc.failed++
+			failed = append(failed, chat.String())
+		}
+		slog.Warn("broadcast: send failed", "id", id, "chat", chat, "err", err)
+	}
+
+	if err = finishBroadcast(b.db, bc); err != nil {
+		slog.Error("broadcast: save result", "id", id, "err", err)
+	}
+	slog.Info("broadcast: done", "id", id, "delivered", bc.delivered, "failed", bc.failed, "blocked", bc.blocked,
+		"latency", time.Since(started))
+
+	report := fmt.Sprintf(broadcastReportMsg, bc.id, bc.delivered, bc.failed, bc.blocked)
+	if len(failed) > 0 {
+		report += fmt.Sprintf(broadcastFailedMsg, strings.Join(failed, ", "))
+	}
+	if len(blocked) > 0 {
+		report += fmt.Sprintf(broadcastBlockedMsg, strings.Join(blocked, ", "))
+	}
+	if err = b.ms.send(bc.chat, report); err != nil {
+		slog.Error("broadcast: send report", "id", id, "chat", bc.chat, "err", err)
+	}
+}
+
+func (b *broadcaster) fail(id int64) {
+	if _, err := setBroadcastStatus(b.db, id, BROADCAST_SENDING, BROADCAST_FAILED); err != nil {
+		slog.Error("broadcast: mark failed", "id", id, "err", err)
+	}
+}
+
+func (b *broadcaster) sendWithRetry(chat chatID, message string) error {
+
+	// Функция sendWithRetry() отправляет сообщение рассылки, соблюдая ограничение скорости.
+	// При временной ошибке отправка повторяется, а если бот заблокирован в чате, повторять ее бессмысленно.
+
+	var err error
+	delay := BROADCAST_RETRY_DELAY
+	for attempt := 1; attempt <= BROADCAST_ATTEMPTS; attempt++ {
+		<-b.throttle.C
+		if err = b.ms.send(chat, message); err == nil || errors.Is(err, errChatBlocked) || errors.Is(err, errMessengerMissing) {
+			return err
+		}
+		if attempt < BROADCAST_ATTEMPTS {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfirmBroadcastQueueFull(t *testing.T) {
+
+	// Если очередь рассылок заполнена, подтверждение не зависает: автор узнает об этом, а рассылка остается черновиком.
+
+	db := newTestDB(t)
+	ms := newFakeMessenger()
+	chat := chatID{PLATFORM_VK, 7}
+
+	// Очередь без места и без получателя: любая попытка дождаться места в ней зависла бы.
+	b := &broadcaster{db: db, ms: messengers{PLATFORM_VK: ms}, queue: make(chan int64), throttle: time.NewTicker(time.Millisecond)}
+	defer b.throttle.Stop()
+
+	id, err := createBroadcast(db, broadcast{chat: chat, user: chat, target: broadcastTarget{kind: TARGET_ALL}, text: "привет"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &request{
+		chat:       chat,
+		user:       chat,
+		config:     &Config{AdminID: 7, Location: scheduleLocation},
+		db:         db,
+		messenger:  ms,
+		messengers: messengers{PLATFORM_VK: ms},
+		broadcasts: b,
+	}
+	done := make(chan bool)
+	go func() {
+		done <- newRouter(botCommands()...).dispatch(r, fmt.Sprintf("/upd подтвердить %d", id))
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("confirm blocks on a full queue")
+	}
+
+	if reply := strings.Join(ms.messages(chat.ID), "\n"); reply != fmt.Sprintf(broadcastQueueFullMsg, id, id) {
+		t.Errorf("reply = %q", reply)
+	}
+	bc, _, err := getBroadcast(db, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bc.status != BROADCAST_DRAFT {
+		t.Errorf("status = %q, want %q", bc.status, BROADCAST_DRAFT)
+	}
+}
+
+func TestDeliverRecipientsFailure(t *testing.T) {
+
+	// Если не удалось получить список получателей, рассылка помечается неудавшейся, а автор получает сообщение об этом.
+
+	db := newTestDB(t)
+	ms := newFakeMessenger()
+	chat := chatID{PLATFORM_VK, 7}
+	b := newBroadcaster(db, messengers{PLATFORM_VK: ms}, nil)
+	defer b.throttle.Stop()
+
+	id, err := createBroadcast(db, broadcast{chat: chat, user: chat, target: broadcastTarget{kind: TARGET_ALL}, text: "привет"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = setBroadcastStatus(db, id, BROADCAST_DRAFT, BROADCAST_QUEUED); err != nil {
+		t.Fatal(err)
+	}
+
+	// Без таблицы ассоциаций получатели не находятся, а сама рассылка читается и обновляется.
+	if _, err = db.Exec("drop table binds"); err != nil {
+		t.Fatal(err)
+	}
+	b.deliver(id)
+
+	bc, _, err := getBroadcast(db, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bc.status != BROADCAST_FAILED {
+		t.Errorf("status = %q, want %q", bc.status, BROADCAST_FAILED)
+	}
+	if reply := strings.Join(ms.messages(chat.ID), "\n"); reply != fmt.Sprintf(broadcastNotStartedMsg, id) {
+		t.Errorf("reply = %q", reply)
+	}
+}
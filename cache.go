@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Метаданные закешированного файла расписания группы.
+// Хранятся рядом с .ics файлом в виде *номер_группы*.json.
+type cacheEntry struct {
+	FetchedAt    time.Time `json:"fetched_at"`
+	ETag         string    `json:"etag"`
+	LastModified string    `json:"last_modified"`
+}
+
+// Кеш файлов расписания. Хранит последнюю скачанную копию расписания каждой группы и,
+// по истечении ttl, перепроверяет её на сайте условным запросом (If-None-Match/If-Modified-Since).
+type scheduleCache struct {
+	dir     string
+	baseURL string
+	ttl     time.Duration
+	client  *http.Client
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newScheduleCache(dir string, baseURL string, ttl time.Duration) *scheduleCache {
+	return &scheduleCache{
+		dir:     dir,
+		baseURL: baseURL,
+		ttl:     ttl,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		locks:   make(map[string]*sync.Mutex),
+	}
+}
+
+func (c *scheduleCache) path(groupNumber string) string {
+	return filepath.Join(c.dir, groupNumber+".ics")
+}
+
+func (c *scheduleCache) metaPath(groupNumber string) string {
+	return filepath.Join(c.dir, groupNumber+".json")
+}
+
+func (c *scheduleCache) groupLock(groupNumber string) *sync.Mutex {
+
+	// Для каждой группы заводится отдельный мьютекс, чтобы одновременные запросы
+	// одной и той же группы не скачивали и не перезаписывали файл параллельно.
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.locks[groupNumber]
+	if !ok {
+		l = new(sync.Mutex)
+		c.locks[groupNumber] = l
+	}
+	return l
+}
+
+func (c *scheduleCache) readEntry(groupNumber string) (cacheEntry, bool) {
+
+	// Функция readEntry() возвращает метаданные закешированного расписания группы.
+	// Если файла расписания или метаданных нет, возвращается отрицательный результат.
+
+	var entry cacheEntry
+
+	if _, err := os.Stat(c.path(groupNumber)); err != nil {
+		return entry, false
+	}
+
+	data, err := os.ReadFile(c.metaPath(groupNumber))
+	if err != nil {
+		return entry, false
+	}
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+func (c *scheduleCache) writeEntry(groupNumber string, entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.metaPath(groupNumber), data, 0644)
+}
+
+func (c *scheduleCache) get(group groupInfo) (string, error) {
+
+	// Функция get() возвращает путь к актуальному файлу расписания группы.
+
+	return c.getURL(group.Slug, getScheduleURL(c.baseURL, group))
+}
+
+func (c *scheduleCache) getURL(groupNumber string, url string) (string, error) {
+
+	// Функция getURL() возвращает путь к актуальному файлу расписания, скачиваемому по ссылке url.
+	// Пока копия свежее ttl, она отдается без обращения к сайту. После этого расписание
+	// перепроверяется условным запросом, а если сайт недоступен - используется последняя сохраненная копия.
+
+	l := c.groupLock(groupNumber)
+	l.Lock()
+	defer l.Unlock()
+
+	entry, cached := c.readEntry(groupNumber)
+	if cached && time.Since(entry.FetchedAt) < c.ttl {
+		scheduleFetchesTotal.WithLabelValues("hit").Inc()
+		return c.path(groupNumber), nil
+	}
+
+	started := time.Now()
+	err := c.fetch(groupNumber, url, &entry, cached)
+	downloadSeconds.Observe(time.Since(started).Seconds())
+
+	if err != nil {
+		scheduleFetchesTotal.WithLabelValues("error").Inc()
+		if cached {
+			slog.Warn("schedule cache: using stale copy", "group", groupNumber, "err", err)
+			return c.path(groupNumber), nil
+		}
+		return "", err
+	}
+	scheduleFetchesTotal.WithLabelValues("miss").Inc()
+	return c.path(groupNumber), nil
+}
+
+func (c *scheduleCache) cached() []string {
+
+	// Функция cached() возвращает названия всех расписаний, уже сохраненных в кеше, без обращения к сайту.
+
+	files, err := filepath.Glob(filepath.Join(c.dir, "*.ics"))
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, f := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(f), ".ics"))
+	}
+	return names
+}
+
+func (c *scheduleCache) fetch(groupNumber string, url string, entry *cacheEntry, cached bool) error {
+
+	// Функция fetch() скачивает расписание с сайта в формате .ics (iCalendar).
+	// При наличии закешированной копии, запрос делается условным, и ответ 304 лишь продлевает срок ее жизни.
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if cached {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSiteUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+		entry.FetchedAt = time.Now()
+		return c.writeEntry(groupNumber, *entry)
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", errGroupNotFound, req.URL)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%w: unexpected status %s for %s", errSiteUnavailable, resp.Status, req.URL)
+	}
+
+	// Ответ сначала пишется во временный файл и только после успешного скачивания
+	// подменяет старую копию, чтобы оборванная загрузка не испортила кеш.
+	if err = os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, groupNumber+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%w: %v", errSiteUnavailable, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), c.path(groupNumber)); err != nil {
+		return err
+	}
+
+	*entry = cacheEntry{
+		FetchedAt:    time.Now(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	return c.writeEntry(groupNumber, *entry)
+}
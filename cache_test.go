@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduleCacheRevalidation(t *testing.T) {
+
+	// Пока копия свежее ttl, сайт не запрашивается. Потом копия перепроверяется условным запросом:
+	// ответ 304 оставляет файл как есть и продлевает срок его жизни, а новое расписание заменяет файл.
+
+	const lastModified = "Mon, 09 Jan 2023 08:00:00 GMT"
+	var mu sync.Mutex
+	var requests []http.Header
+	version, body := `"v1"`, testCalendarHeader+testCalendarFooter
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Header.Clone())
+
+		if r.Header.Get("If-None-Match") == version {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", version)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	cache := newScheduleCache(t.TempDir(), server.URL, time.Hour)
+	link := server.URL + "/431-2.ics"
+
+	get := func(wantRequests int, wantBody string) {
+		t.Helper()
+		fileName, err := cache.getURL("431-2", link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != wantBody {
+			t.Errorf("file = %q, want %q", content, wantBody)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(requests) != wantRequests {
+			t.Fatalf("site requested %d times, want %d", len(requests), wantRequests)
+		}
+	}
+	expire := func() {
+		t.Helper()
+		entry, ok := cache.readEntry("431-2")
+		if !ok {
+			t.Fatal("no cache entry")
+		}
+		entry.FetchedAt = time.Now().Add(-2 * time.Hour)
+		if err := cache.writeEntry("431-2", entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Первая загрузка - обычный запрос, повторная в пределах ttl к сайту не обращается.
+	first := body
+	get(1, first)
+	if h := requests[0]; h.Get("If-None-Match") != "" || h.Get("If-Modified-Since") != "" {
+		t.Errorf("first request is conditional: %v", h)
+	}
+	get(1, first)
+
+	// Устаревшая копия перепроверяется с сохраненными ETag и Last-Modified, и ответ 304 продлевает ее.
+	expire()
+	get(2, first)
+	if h := requests[1]; h.Get("If-None-Match") != `"v1"` || h.Get("If-Modified-Since") != lastModified {
+		t.Errorf("revalidation headers = %v", h)
+	}
+	if entry, _ := cache.readEntry("431-2"); time.Since(entry.FetchedAt) > time.Minute || entry.ETag != `"v1"` {
+		t.Errorf("entry after 304 = %+v", entry)
+	}
+	get(2, first)
+
+	// Если расписание на сайте изменилось, файл и метаданные заменяются новыми.
+	mu.Lock()
+	version, body = `"v2"`, testCalendarHeader+testEvent("20230110T084500", "20230110T102000", "Физика", "Практика", "рк 202")+testCalendarFooter
+	updated := body
+	mu.Unlock()
+
+	expire()
+	get(3, updated)
+	if entry, _ := cache.readEntry("431-2"); entry.ETag != `"v2"` {
+		t.Errorf("entry after update = %+v", entry)
+	}
+}
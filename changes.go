@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/stephenafamo/kronika"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// На сколько дней вперед отслеживаются изменения. Изменения в прошедших и далеких днях не интересны.
+const CHANGES_HORIZON_DAYS = 14
+
+// Вид изменения занятия.
+type changeKind int
+
+const (
+	changeAdded   changeKind = iota // Занятие появилось.
+	changeRemoved                   // Занятие отменено.
+	changeMoved                     // Занятие перенесено на другое время.
+	changeUpdated                   // У занятия изменилась аудитория, преподаватель или время окончания.
+)
+
+// Изменение занятия между двумя версиями расписания. Для добавленного занятия old пуст, для отмененного - new.
+type lessonChange struct {
+	kind changeKind
+	old  Lesson
+	new  Lesson
+}
+
+func (c lessonChange) start() time.Time {
+	if c.kind == changeRemoved {
+		return c.old.Start
+	}
+	return c.new.Start
+}
+
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameRooms(a []Room, b []Room) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameSubject(a Lesson, b Lesson) bool {
+	return a.Subject == b.Subject && a.KindName == b.KindName && a.Subgroup == b.Subgroup
+}
+
+func sameSlot(a Lesson, b Lesson) bool {
+	return sameSubject(a, b) && a.Start.Equal(b.Start)
+}
+
+func sameLesson(a Lesson, b Lesson) bool {
+	return sameSlot(a, b) && a.End.Equal(b.End) && sameRooms(a.Rooms, b.Rooms) && sameStrings(a.Teachers, b.Teachers)
+}
+
+func movedLesson(a Lesson, b Lesson) bool {
+
+	// Занятие считается перенесенным, если тот же предмет того же вида появился в пределах недели от прежнего времени.
+
+	d := a.Start.Sub(b.Start)
+	return sameSubject(a, b) && d < 7*24*time.Hour && d > -7*24*time.Hour
+}
+
+func matchLessons(old []Lesson, new []Lesson, match func(Lesson, Lesson) bool) ([][2]Lesson, []Lesson, []Lesson) {
+
+	// Функция matchLessons() находит пары занятий из двух версий расписания, удовлетворяющие условию match.
+	// Каждое занятие входит не более чем в одну пару, оставшиеся без пары занятия возвращаются отдельно.
+
+	var pairs [][2]Lesson
+	var restOld []Lesson
+	used := make([]bool, len(new))
+
+	for _, o := range old {
+		found := false
+		for i, n := range new {
+			if !used[i] && match(o, n) {
+				used[i] = true
+				found = true
+				pairs = append(pairs, [2]Lesson{o, n})
+				break
+			}
+		}
+		if !found {
+			restOld = append(restOld, o)
+		}
+	}
+
+	var restNew []Lesson
+	for i, n := range new {
+		if !used[i] {
+			restNew = append(restNew, n)
+		}
+	}
+	return pairs, restOld, restNew
+}
+
+func diffLessons(old []Lesson, new []Lesson) []lessonChange {
+
+	// Функция diffLessons() сравнивает две версии расписания и возвращает изменения, отсортированные по времени.
+	// Занятия сопоставляются от самого строгого условия к самому слабому:
+	// 	1. Полностью совпадающие занятия не являются изменениями;
+	// 	2. Тот же предмет в то же время - изменились аудитория, преподаватель или время окончания;
+	// 	3. Тот же предмет в пределах недели - занятие перенесено;
+	// 	4. Все остальное - отмененные и добавленные занятия.
+
+	var changes []lessonChange
+
+	_, old, new = matchLessons(old, new, sameLesson)
+
+	updated, old, new := matchLessons(old, new, sameSlot)
+	for _, p := range updated {
+		changes = append(changes, lessonChange{kind: changeUpdated, old: p[0], new: p[1]})
+	}
+
+	moved, old, new := matchLessons(old, new, movedLesson)
+	for _, p := range moved {
+		changes = append(changes, lessonChange{kind: changeMoved, old: p[0], new: p[1]})
+	}
+
+	for _, l := range old {
+		changes = append(changes, lessonChange{kind: changeRemoved, old: l})
+	}
+	for _, l := range new {
+		changes = append(changes, lessonChange{kind: changeAdded, new: l})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].start().Before(changes[j].start())
+	})
+	return changes
+}
+
+func viewChanges(view scheduleView, changes []lessonChange) []lessonChange {
+
+	// Функция viewChanges() оставляет изменения, которые видны чату с его подгруппой и фильтрами.
+	// Изменение показывается, если чату видна прежняя или новая версия занятия.
+
+	visible := func(l Lesson) bool {
+		shown, _ := view.apply([]Lesson{l})
+		return len(shown) > 0
+	}
+
+	var shown []lessonChange
+	for _, c := range changes {
+		if (c.kind != changeAdded && visible(c.old)) || (c.kind != changeRemoved && visible(c.new)) {
+			shown = append(shown, c)
+		}
+	}
+	return shown
+}
+
+func formLessonTitle(l Lesson) string {
+	title := l.Subject
+	if kind := l.KindLabel(); kind != "" {
+		title += " (" + kind + ")"
+	}
+	if label := l.SubgroupLabel(); label != "" {
+		title += ", " + label
+	}
+	return title
+}
+
+func formLessonTime(l Lesson) string {
+	return fmt.Sprintf("%s (%s) %s", l.Start.Format("02.01"), getRuWeekDay(l.Start), l.Start.Format("15:04"))
+}
+
+func formRooms(rooms []Room) string {
+	var names []string
+	for _, room := range rooms {
+		names = append(names, room.String())
+	}
+	if len(names) == 0 {
+		return "не указана"
+	}
+	return strings.Join(names, ", ")
+}
+
+func formTeachers(teachers []string) string {
+	if len(teachers) == 0 {
+		return "не указан"
+	}
+	return strings.Join(teachers, ", ")
+}
+
+func formChange(c lessonChange) string {
+
+	// Функция formChange() формирует строку с описанием одного изменения в расписании.
+
+	switch c.kind {
+	case changeAdded:
+		return fmt.Sprintf("➕ %s, %s, ауд. %s\n", formLessonTitle(c.new), formLessonTime(c.new), formRooms(c.new.Rooms))
+	case changeRemoved:
+		return fmt.Sprintf("➖ Отменено: %s, %s\n", formLessonTitle(c.old), formLessonTime(c.old))
+	case changeMoved:
+		return fmt.Sprintf("🔁 %s: %s → %s, ауд. %s\n", formLessonTitle(c.new), formLessonTime(c.old), formLessonTime(c.new), formRooms(c.new.Rooms))
+	}
+
+	var details []string
+	if !sameRooms(c.old.Rooms, c.new.Rooms) {
+		details = append(details, fmt.Sprintf("аудитория %s → %s", formRooms(c.old.Rooms), formRooms(c.new.Rooms)))
+	}
+	if !sameStrings(c.old.Teachers, c.new.Teachers) {
+		details = append(details, fmt.Sprintf("преподаватель %s → %s", formTeachers(c.old.Teachers), formTeachers(c.new.Teachers)))
+	}
+	if !c.old.End.Equal(c.new.End) {
+		details = append(details, fmt.Sprintf("окончание %s → %s", c.old.End.Format("15:04"), c.new.End.Format("15:04")))
+	}
+	return fmt.Sprintf("✏ %s, %s: %s\n", formLessonTitle(c.new), formLessonTime(c.new), strings.Join(details, "; "))
+}
+
+func formChangesMessages(groupNumber string, changes []lessonChange) []string {
+	blocks := []string{fmt.Sprintf("🔔 Изменения в расписании группы %s:\n\n", groupNumber)}
+	for _, c := range changes {
+		blocks = append(blocks, formChange(c))
+	}
+	return splitMessage(blocks, MESSAGE_LIMIT)
+}
+
+func watchScheduleChanges(cfg *Config, db *sql.DB, ms messengers, schedule *ScheduleService) {
+
+	// Функция watchScheduleChanges() периодически проверяет расписания всех групп, с которыми ассоциированы чаты,
+	// и сообщает этим чатам об изменениях на ближайшие дни. Используется пакет kronika, как и в cronSending().
+
+	if cfg.ChangesInterval <= 0 {
+		return
+	}
+
+	ctx := context.Background()
+	for now := range kronika.Every(ctx, time.Now(), cfg.ChangesInterval) {
+
+		groups, err := getBoundGroups(db)
+		if err != nil {
+			slog.Error("changes: get bound groups", "err", err)
+			continue
+		}
+
+		for _, groupNumber := range groups {
+			if err = checkScheduleChanges(db, ms, schedule, groupNumber, now.In(cfg.Location)); err != nil {
+				slog.Error("changes: check failed", "group", groupNumber, "err", err)
+			}
+		}
+	}
+}
+
+func checkScheduleChanges(db *sql.DB, ms messengers, schedule *ScheduleService, groupNumber string, now time.Time) error {
+
+	// Функция checkScheduleChanges() сравнивает текущее расписание группы с сохраненным при прошлой проверке
+	// и отправляет найденные изменения во все чаты группы. Каждому чату отправляются только изменения занятий,
+	// которые он видит со своей подгруппой и фильтрами. При первой проверке группы расписание только сохраняется.
+
+	defer recoverPanic("changes: group " + groupNumber)
+
+	lessons, err := schedule.AllLessons(groupNumber)
+	if err != nil {
+		return err
+	}
+
+	old, found, err := getScheduleSnapshot(db, groupNumber)
+	if err != nil {
+		return err
+	}
+
+	if found {
+		// Сохраненный снимок размечается заново так же, как свежее расписание: в старых снимках подгруппы могли быть
+		// пронумерованы по порядку событий в календаре, и без пересчета такие занятия выглядели бы измененными.
+		detectSubgroups(old)
+
+		from := now
+		to := now.AddDate(0, 0, CHANGES_HORIZON_DAYS)
+
+		// Прошедшие занятия пропадают из рассмотрения, поэтому сравниваются только занятия, которые еще не начались.
+		changes := diffLessons(filterLessons(old, from, to), filterLessons(lessons, from, to))
+
+		if len(changes) > 0 {
+			chats, err := getGroupChats(db, groupNumber)
+			if err != nil {
+				return err
+			}
+			for _, chat := range chats {
+				shown := viewChanges(getScheduleView(db, chat), changes)
+				if len(shown) == 0 {
+					continue
+				}
+				for _, message := range formChangesMessages(groupNumber, shown) {
+					if err = ms.send(chat, message); err != nil {
+						slog.Error("changes: send failed", "chat", chat, "group", groupNumber, "err", err)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return setScheduleSnapshot(db, groupNumber, lessons)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Мессенджер, который запоминает отправленные сообщения вместо отправки.
+type fakeMessenger struct {
+	mu   sync.Mutex
+	sent map[int64][]string
+}
+
+func newFakeMessenger() *fakeMessenger {
+	return &fakeMessenger{sent: make(map[int64][]string)}
+}
+
+func (m *fakeMessenger) platform() string {
+	return PLATFORM_VK
+}
+
+func (m *fakeMessenger) send(peerID int64, message string, kb *keyboard) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent[peerID] = append(m.sent[peerID], message)
+	return nil
+}
+
+func (m *fakeMessenger) edit(peerID int64, messageID int64, message string, kb *keyboard) error {
+	return m.send(peerID, message, kb)
+}
+
+func (m *fakeMessenger) answer(peerID int64, userID int64, event *buttonEvent) error {
+	return nil
+}
+
+func (m *fakeMessenger) run(ctx context.Context, handler func(incomingMessage)) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (m *fakeMessenger) isChatAdmin(peerID int64, userID int64) (bool, error) {
+	return true, nil
+}
+
+func (m *fakeMessenger) messages(peerID int64) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sent[peerID]
+}
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err = migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestDiffLessons(t *testing.T) {
+	math := testEvent("20221017T084500", "20221017T102000", "Математика", "Лекция\\, Иванов И.И.", "рк 101")
+	physics := testEvent("20221017T104000", "20221017T121500", "Физика", "Практика\\, Петров П.П.", "рк 202")
+
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want []changeKind
+	}{
+		{
+			name: "unchanged",
+			old:  math + physics,
+			new:  physics + math,
+		},
+		{
+			name: "added",
+			old:  math,
+			new:  math + physics,
+			want: []changeKind{changeAdded},
+		},
+		{
+			name: "removed",
+			old:  math + physics,
+			new:  math,
+			want: []changeKind{changeRemoved},
+		},
+		{
+			name: "moved to another day",
+			old:  math + physics,
+			new:  math + testEvent("20221019T104000", "20221019T121500", "Физика", "Практика\\, Петров П.П.", "рк 202"),
+			want: []changeKind{changeMoved},
+		},
+		{
+			name: "moved further than a week",
+			old:  math + physics,
+			new:  math + testEvent("20221028T104000", "20221028T121500", "Физика", "Практика\\, Петров П.П.", "рк 202"),
+			want: []changeKind{changeRemoved, changeAdded},
+		},
+		{
+			name: "room change",
+			old:  math + physics,
+			new:  math + testEvent("20221017T104000", "20221017T121500", "Физика", "Практика\\, Петров П.П.", "рк 303"),
+			want: []changeKind{changeUpdated},
+		},
+		{
+			name: "teacher change",
+			old:  math,
+			new:  testEvent("20221017T084500", "20221017T102000", "Математика", "Лекция\\, Сидоров С.С.", "рк 101"),
+			want: []changeKind{changeUpdated},
+		},
+		{
+			name: "other kind of the same subject",
+			old:  math,
+			new:  testEvent("20221017T084500", "20221017T102000", "Математика", "Практика\\, Иванов И.И.", "рк 101"),
+			want: []changeKind{changeRemoved, changeAdded},
+		},
+		{
+			name: "one of two equal lessons removed",
+			old:  math + testEvent("20221018T084500", "20221018T102000", "Математика", "Лекция\\, Иванов И.И.", "рк 101"),
+			new:  math,
+			want: []changeKind{changeRemoved},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := parseTestCalendar(t, testCalendarHeader+tt.old+testCalendarFooter)
+			new := parseTestCalendar(t, testCalendarHeader+tt.new+testCalendarFooter)
+
+			changes := diffLessons(old, new)
+			if len(changes) != len(tt.want) {
+				t.Fatalf("got %d changes %+v, want %v", len(changes), changes, tt.want)
+			}
+			for i, c := range changes {
+				if c.kind != tt.want[i] {
+					t.Errorf("change %d: kind = %v, want %v", i, c.kind, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDiffLessonsDetails(t *testing.T) {
+
+	// Перенесенное занятие сопоставляется с прежним, а в сообщении об изменении аудитории указаны обе аудитории.
+
+	old := parseTestCalendar(t, testCalendarHeader+
+		testEvent("20221017T104000", "20221017T121500", "Физика", "Практика\\, Петров П.П.", "рк 202")+
+		testEvent("20221018T084500", "20221018T102000", "Химия", "Лекция\\, Смирнов А.А.", "гк 224")+testCalendarFooter)
+	new := parseTestCalendar(t, testCalendarHeader+
+		testEvent("20221019T104000", "20221019T121500", "Физика", "Практика\\, Петров П.П.", "рк 202")+
+		testEvent("20221018T084500", "20221018T102000", "Химия", "Лекция\\, Смирнов А.А.", "гк 225")+testCalendarFooter)
+
+	changes := diffLessons(old, new)
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2", len(changes))
+	}
+
+	if c := changes[0]; c.kind != changeUpdated || formChange(c) != "✏ Химия (Лекция), 18.10 (Вторник) 08:45: аудитория гк 224 → гк 225\n" {
+		t.Errorf("room change: %q", formChange(c))
+	}
+	if c := changes[1]; c.kind != changeMoved || !c.old.Start.Equal(old[0].Start) || !c.new.Start.Equal(new[0].Start) {
+		t.Errorf("move: %+v", c)
+	}
+}
+
+func TestCheckScheduleChangesView(t *testing.T) {
+
+	// Изменения отправляются каждому чату группы с учетом его подгруппы и фильтров,
+	// а чату, которому не видно ни одного изменения, сообщение не отправляется.
+
+	db := newTestDB(t)
+	ms := newFakeMessenger()
+	now := time.Date(2022, 10, 16, 12, 0, 0, 0, scheduleLocation)
+
+	first := testEvent("20221017T084500", "20221017T102000", "Информатика (1 подгр.)", "Лабораторная работа\\, Смирнов А.А.", "рк 418")
+	second := testEvent("20221017T104000", "20221017T121500", "Физкультура", "Практика\\, Петров П.П.", "спорткомплекс")
+	schedule := newTestSchedule(t, map[string]string{"431-2": testCalendarHeader + testCalendarFooter})
+	old, err := schedule.AllLessons("431-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setScheduleSnapshot(db, "431-2", old); err != nil {
+		t.Fatal(err)
+	}
+	schedule = newTestSchedule(t, map[string]string{"431-2": testCalendarHeader + first + second + testCalendarFooter})
+
+	all := chatID{PLATFORM_VK, 1}
+	secondSubgroup := chatID{PLATFORM_VK, 2}
+	noSport := chatID{PLATFORM_VK, 3}
+	hideAll := chatID{PLATFORM_VK, 4}
+	for _, chat := range []chatID{all, secondSubgroup, noSport, hideAll} {
+		if _, err = addBinding(db, chat, "431-2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = setChatSetting(db, secondSubgroup, SETTING_SUBGROUP, "2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = addLessonFilter(db, noSport, lessonFilter{action: FILTER_HIDE, field: FILTER_SUBJECT, pattern: "физкультура"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = setChatSetting(db, hideAll, SETTING_SUBGROUP, "2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = addLessonFilter(db, hideAll, lessonFilter{action: FILTER_HIDE, field: FILTER_SUBJECT, pattern: "физкультура"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = checkScheduleChanges(db, messengers{PLATFORM_VK: ms}, schedule, "431-2", now); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		chat chatID
+		want []string
+		skip []string
+	}{
+		{all, []string{"Информатика", "Физкультура"}, nil},
+		{secondSubgroup, []string{"Физкультура"}, []string{"Информатика"}},
+		{noSport, []string{"Информатика"}, []string{"Физкультура"}},
+	}
+	for _, tt := range tests {
+		message := strings.Join(ms.messages(tt.chat.ID), "")
+		for _, s := range tt.want {
+			if !strings.Contains(message, s) {
+				t.Errorf("%s: %q not in %q", tt.chat, s, message)
+			}
+		}
+		for _, s := range tt.skip {
+			if strings.Contains(message, s) {
+				t.Errorf("%s: %q in %q", tt.chat, s, message)
+			}
+		}
+	}
+	if sent := ms.messages(hideAll.ID); len(sent) != 0 {
+		t.Errorf("%s: got %q, want nothing", hideAll, sent)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Запрос к боту: входящее сообщение, разобранная команда и все, что нужно обработчику для ответа.
+type request struct {
+	chat    chatID
+	user    chatID       // Автор сообщения.
+	event   *buttonEvent // Нажатие на кнопку встроенной клавиатуры, если команда пришла так.
+	text    string       // Текст сообщения после названия команды, в исходном регистре.
+	args    []string     // Аргументы команды в нижнем регистре.
+	command *command
+	router  *router
+
+	group   string // Группа, к которой относится запрос, для записи в лог.
+	outcome string // Результат обработки для лога и журнала действий: ok, error, denied или panic.
+
+	config     *Config
+	db         *sql.DB
+	messenger  messenger
+	messengers messengers
+	schedule   *ScheduleService
+	broadcasts *broadcaster
+}
+
+func (r *request) reply(message string) {
+
+	// Функция reply() отправляет ответ в чат, из которого пришло сообщение, через мессенджер, из которого оно получено.
+
+	if err := r.messenger.send(r.chat.ID, message, nil); err != nil {
+		slog.Error("reply failed", "chat", r.chat, "err", err)
+	}
+}
+
+func (r *request) replyKeyboard(message string, kb *keyboard) {
+
+	// Функция replyKeyboard() отправляет ответ с клавиатурой. Если команда пришла нажатием на кнопку встроенной клавиатуры,
+	// а ответ - тоже встроенная клавиатура (например, следующий экран меню), заменяется сообщение с нажатой кнопкой.
+
+	if r.event != nil && kb != nil && kb.inline {
+		err := r.messenger.edit(r.chat.ID, r.event.messageID, message, kb)
+		if err == nil {
+			return
+		}
+		slog.Warn("edit failed, sending new message", "chat", r.chat, "err", err)
+	}
+	if err := r.messenger.send(r.chat.ID, message, kb); err != nil {
+		slog.Error("reply failed", "chat", r.chat, "err", err)
+	}
+}
+
+func (r *request) replyError(err error) {
+
+	// Функция replyError() записывает ошибку в лог и сообщает пользователю понятную причину, по которой команда не выполнена.
+
+	r.outcome = "error"
+	slog.Warn("command failed", "chat", r.chat, "command", r.command.names[0], "group", r.group, "err", err)
+	r.reply(userError(err))
+}
+
+// Команда бота. Сообщение относится к команде, если начинается с одного из ее названий.
+type command struct {
+	names   []string // Первое название - основное, остальные - синонимы.
+	usage   string   // Аргументы команды для справки, например "*номер_группы*".
+	help    string
+	access  accessLevel // Кто может использовать команду. По умолчанию - любой пользователь.
+	handler func(r *request)
+}
+
+// Маршрутизатор команд. Хранит все команды бота и определяет, к какой из них относится сообщение.
+type router struct {
+	commands []*command
+	names    []string // Все названия команд, от самых длинных к самым коротким.
+	byName   map[string]*command
+}
+
+// Упоминание бота в беседе, которое ВК добавляет в начало сообщения: "[club123|@tusurschedulebot] ".
+var mentionRe = regexp.MustCompile(`^\[(club|public)\d+\|[^\]]*\][,\s]*`)
+
+func newRouter(commands ...*command) *router {
+	rt := &router{byName: make(map[string]*command)}
+	for _, c := range commands {
+		rt.register(c)
+	}
+	return rt
+}
+
+func (rt *router) register(c *command) {
+	rt.commands = append(rt.commands, c)
+	for _, name := range c.names {
+		rt.byName[name] = c
+		rt.names = append(rt.names, name)
+	}
+
+	// Названия проверяются от длинных к коротким, чтобы "расписос на завтра" не считался командой "расписос".
+	sort.SliceStable(rt.names, func(i, j int) bool {
+		return utf8.RuneCountInString(rt.names[i]) > utf8.RuneCountInString(rt.names[j])
+	})
+}
+
+func (rt *router) match(text string) (*command, string) {
+
+	// Функция match() находит команду, с названия которой начинается сообщение, и возвращает ее вместе с остатком текста.
+	// Название должно быть отделено от аргументов пробелом, поэтому "/bindings" не считается командой "/bind".
+
+	text = strings.TrimSpace(mentionRe.ReplaceAllString(strings.TrimSpace(text), ""))
+	runes := []rune(text)
+
+	for _, name := range rt.names {
+		n := utf8.RuneCountInString(name)
+		if len(runes) < n || !strings.EqualFold(string(runes[:n]), name) {
+			continue
+		}
+		rest := string(runes[n:])
+		if rest != "" && !strings.ContainsAny(rest[:1], " \t\n") {
+			continue
+		}
+		return rt.byName[name], strings.TrimSpace(rest)
+	}
+	return nil, ""
+}
+
+func (rt *router) dispatch(r *request, text string) bool {
+
+	// Функция dispatch() вызывает обработчик команды, к которой относится сообщение.
+	// Если сообщение не является командой, возвращается отрицательный результат.
+
+	c, rest := rt.match(text)
+	if c == nil {
+		messagesTotal.WithLabelValues("none").Inc()
+		return false
+	}
+	messagesTotal.WithLabelValues(c.names[0]).Inc()
+
+	r.command = c
+	r.router = rt
+	r.text = rest
+	r.args = strings.Fields(strings.ToLower(rest))
+	r.outcome = "ok"
+
+	// После обработки каждой команды в лог записывается ее результат, а служебные команды - еще и в журнал действий в БД.
+	// Паника при обработке одного сообщения не должна останавливать бота.
+	started := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			r.outcome = "panic"
+			slog.Error("command panic", "chat", r.chat, "command", c.names[0], "panic", p, "stack", string(debug.Stack()))
+			r.reply(unhandledErrMsg)
+		}
+
+		slog.Info("command", "chat", r.chat, "user", r.user, "command", c.names[0], "group", r.group, "latency", time.Since(started), "outcome", r.outcome)
+		if c.access >= accessModerator {
+			if err := addAuditRecord(r.db, r.chat, r.user, c.names[0], r.text, r.outcome); err != nil {
+				slog.Error("audit log", "chat", r.chat, "user", r.user, "command", c.names[0], "err", err)
+			}
+		}
+	}()
+
+	// Служебные команды и настройки чата доступны не всем.
+	if !r.require(c.access) {
+		return true
+	}
+
+	c.handler(r)
+	return true
+}
+
+func (rt *router) help() string {
+
+	// Функция help() формирует справку по всем пользовательским командам из их описаний.
+	// Служебные команды администраторов бота в справку не попадают.
+
+	var message = "Команды бота:\n\n"
+	for _, c := range rt.commands {
+		if c.access >= accessModerator {
+			continue
+		}
+		message += "▶ " + c.names[0]
+		if c.usage != "" {
+			message += " " + c.usage
+		}
+		message += " - " + c.help + "\n"
+		if len(c.names) > 1 {
+			message += "   Синонимы: " + strings.Join(c.names[1:], ", ") + "\n"
+		}
+	}
+	return message + "\n" + helpFooterMsg
+}
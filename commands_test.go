@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRouterMatch(t *testing.T) {
+	rt := newRouter(botCommands()...)
+	tg := newTelegramMessenger("http://127.0.0.1:0", "token")
+
+	tests := []struct {
+		text    string
+		command string // Основное название команды, "" - сообщение не является командой.
+		rest    string
+	}{
+		// Названия, начинающиеся одинаково.
+		{"/unbind", "/unbind", ""},
+		{"/unbind 431-2", "/unbind", "431-2"},
+		{"/bind 431-2", "/bind", "431-2"},
+		{"/bindings", "", ""},
+		{"расписос", "расписос", ""},
+		{"расписос на завтра", "расписос на завтра", ""},
+		{"расписос на завтра 431-2", "расписос на завтра", "431-2"},
+		{"расписос на неделю", "расписос на неделю", ""},
+		{"расписос на следующую неделю 431-2", "расписос на следующую неделю", "431-2"},
+		{"расписос 431-2 в пятницу", "расписос", "431-2 в пятницу"},
+		{"Расписос НА ЗАВТРА", "расписос на завтра", ""},
+
+		// Упоминание бота в беседе ВК перед командой и имя бота после команды в Telegram.
+		{"[club123|@tusurschedulebot] /bind 431-2", "/bind", "431-2"},
+		{"[public123|Бот], расписос", "расписос", ""},
+		{tg.commandText("/bind@TusurScheduleBot 431-2"), "/bind", "431-2"},
+		{tg.commandText("/unbind@TusurScheduleBot"), "/unbind", ""},
+
+		// Название команды посреди обычного сообщения не должно ее вызывать.
+		{"кто знает, где расписос?", "", ""},
+		{"напишите /bind в беседе", "", ""},
+		{"привет", "", ""},
+		{"", "", ""},
+
+		// Русские и английские синонимы.
+		{"/привязать 431-2", "/bind", "431-2"},
+		{"/отвязать", "/unbind", ""},
+		{"/сегодня", "расписос", ""},
+		{"/today 431-2", "расписос", "431-2"},
+		{"/завтра", "расписос на завтра", ""},
+		{"/tomorrow", "расписос на завтра", ""},
+		{"/неделя", "расписос на неделю", ""},
+		{"/следующаянеделя", "расписос на следующую неделю", ""},
+		{"помощь", "/help", ""},
+		{"/start", "/help", ""},
+		{"/уведомления 08:00 сегодня", "/notify", "08:00 сегодня"},
+		{"следующая пара", "что сейчас", ""},
+		{"/препод иванов", "препод", "иванов"},
+		{"экзамены напоминания вкл", "экзамены", "напоминания вкл"},
+	}
+
+	for _, tt := range tests {
+		c, rest := rt.match(tt.text)
+		var got string
+		if c != nil {
+			got = c.names[0]
+		}
+		if got != tt.command || rest != tt.rest {
+			t.Errorf("match(%q) = %q, %q; want %q, %q", tt.text, got, rest, tt.command, tt.rest)
+		}
+	}
+}
+
+func TestDispatchAuditRecord(t *testing.T) {
+
+	// Служебные команды записываются в журнал действий вместе с автором, в том числе отклоненные.
+
+	db := newTestDB(t)
+	ms := newFakeMessenger()
+	rt := newRouter(botCommands()...)
+	chat := chatID{PLATFORM_VK, 2000000001}
+
+	for _, user := range []int64{7, 8} {
+		r := &request{
+			chat:       chat,
+			user:       chatID{PLATFORM_VK, user},
+			config:     &Config{AdminID: 7, Location: scheduleLocation},
+			db:         db,
+			messenger:  ms,
+			messengers: messengers{PLATFORM_VK: ms},
+		}
+		rt.dispatch(r, "/db")
+	}
+
+	rows, err := db.Query("select peer_id, user_id, command, outcome from audit_log order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	type record struct {
+		peerID, userID   int64
+		command, outcome string
+	}
+	var got []record
+	for rows.Next() {
+		var rec record
+		if err = rows.Scan(&rec.peerID, &rec.userID, &rec.command, &rec.outcome); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, rec)
+	}
+	want := []record{{chat.ID, 7, "/db", "ok"}, {chat.ID, 8, "/db", "denied"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("audit log = %+v, want %+v", got, want)
+	}
+}
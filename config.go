@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config - настройки экземпляра бота. Загружаются из YAML-файла и могут быть переопределены переменными окружения TSB_*.
+type Config struct {
+	VKToken            string        `yaml:"vk_token"`
+	AdminID            int           `yaml:"admin_id"`
+	TelegramToken      string        `yaml:"telegram_token"`
+	TelegramAPIURL     string        `yaml:"telegram_api_url"`
+	TelegramAdminID    int64         `yaml:"telegram_admin_id"`
+	DBPath             string        `yaml:"db_path"`
+	GroupsDir          string        `yaml:"groups_dir"`
+	TimetableURL       string        `yaml:"timetable_url"`
+	CacheTTL           time.Duration `yaml:"cache_ttl"`
+	MorningTime        string        `yaml:"morning_time"` // Время рассылки расписания на сегодня, ЧЧ:ММ.
+	EveningTime        string        `yaml:"evening_time"` // Время рассылки расписания на завтра, ЧЧ:ММ.
+	SundayWeekSchedule bool          `yaml:"sunday_week_schedule"`
+	ChangesInterval    time.Duration `yaml:"changes_interval"` // Период проверки изменений в расписании, 0 - не проверять.
+	Timezone           string        `yaml:"timezone"`         // Часовой пояс, в котором работают рассылки и команды "сегодня"/"завтра".
+	LogLevel           string        `yaml:"log_level"`        // Минимальный уровень записей в логе: debug, info, warn, error.
+	LogFormat          string        `yaml:"log_format"`       // Формат лога: text или json.
+	MetricsAddr        string        `yaml:"metrics_addr"`     // Адрес HTTP-сервера с метриками Prometheus, например ":9090". Пустой - не запускать.
+
+	Location *time.Location `yaml:"-"`
+}
+
+// Путь к файлу настроек, если он не задан переменной окружения TSB_CONFIG.
+const DEFAULT_CONFIG_PATH = "./config.yaml"
+
+func defaultConfig() Config {
+	return Config{
+		TelegramAPIURL:     "https://api.telegram.org",
+		DBPath:             "./sqlite.db",
+		GroupsDir:          "./groups/",
+		TimetableURL:       "https://timetable.tusur.ru",
+		CacheTTL:           30 * time.Minute,
+		MorningTime:        "08:00",
+		EveningTime:        "20:00",
+		SundayWeekSchedule: true,
+		ChangesInterval:    time.Hour,
+		Timezone:           "Asia/Tomsk",
+		LogLevel:           "info",
+		LogFormat:          "text",
+	}
+}
+
+func loadConfig() (*Config, error) {
+
+	// Функция loadConfig() собирает настройки бота: значения по умолчанию, затем файл настроек, затем переменные окружения.
+	// Файл по умолчанию может отсутствовать, если все нужное задано через окружение, а явно указанный в TSB_CONFIG - обязателен.
+
+	cfg := defaultConfig()
+
+	path, explicit := os.LookupEnv("TSB_CONFIG")
+	if !explicit {
+		path = DEFAULT_CONFIG_PATH
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err = yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("config %s: %w", path, err)
+		}
+	case explicit || !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	if err = cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err = cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (cfg *Config) applyEnv() error {
+
+	// Функция applyEnv() переопределяет настройки значениями переменных окружения, если они заданы.
+
+	if v, ok := os.LookupEnv("TSB_VK_TOKEN"); ok {
+		cfg.VKToken = v
+	}
+	if v, ok := os.LookupEnv("TSB_ADMIN_ID"); ok {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("config: TSB_ADMIN_ID: %q is not a number", v)
+		}
+		cfg.AdminID = id
+	}
+	if v, ok := os.LookupEnv("TSB_TELEGRAM_TOKEN"); ok {
+		cfg.TelegramToken = v
+	}
+	if v, ok := os.LookupEnv("TSB_TELEGRAM_API_URL"); ok {
+		cfg.TelegramAPIURL = v
+	}
+	if v, ok := os.LookupEnv("TSB_TELEGRAM_ADMIN_ID"); ok {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("config: TSB_TELEGRAM_ADMIN_ID: %q is not a number", v)
+		}
+		cfg.TelegramAdminID = id
+	}
+	if v, ok := os.LookupEnv("TSB_DB_PATH"); ok {
+		cfg.DBPath = v
+	}
+	if v, ok := os.LookupEnv("TSB_GROUPS_DIR"); ok {
+		cfg.GroupsDir = v
+	}
+	if v, ok := os.LookupEnv("TSB_TIMETABLE_URL"); ok {
+		cfg.TimetableURL = v
+	}
+	if v, ok := os.LookupEnv("TSB_CACHE_TTL"); ok {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("config: TSB_CACHE_TTL: %q is not a duration", v)
+		}
+		cfg.CacheTTL = ttl
+	}
+	if v, ok := os.LookupEnv("TSB_MORNING_TIME"); ok {
+		cfg.MorningTime = v
+	}
+	if v, ok := os.LookupEnv("TSB_EVENING_TIME"); ok {
+		cfg.EveningTime = v
+	}
+	if v, ok := os.LookupEnv("TSB_CHANGES_INTERVAL"); ok {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("config: TSB_CHANGES_INTERVAL: %q is not a duration", v)
+		}
+		cfg.ChangesInterval = interval
+	}
+	if v, ok := os.LookupEnv("TSB_TIMEZONE"); ok {
+		cfg.Timezone = v
+	}
+	if v, ok := os.LookupEnv("TSB_LOG_LEVEL"); ok {
+		cfg.LogLevel = v
+	}
+	if v, ok := os.LookupEnv("TSB_LOG_FORMAT"); ok {
+		cfg.LogFormat = v
+	}
+	if v, ok := os.LookupEnv("TSB_METRICS_ADDR"); ok {
+		cfg.MetricsAddr = v
+	}
+	if v, ok := os.LookupEnv("TSB_SUNDAY_WEEK_SCHEDULE"); ok {
+		flag, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: TSB_SUNDAY_WEEK_SCHEDULE: %q is not a boolean", v)
+		}
+		cfg.SundayWeekSchedule = flag
+	}
+	return nil
+}
+
+func (cfg *Config) validate() error {
+
+	// Функция validate() проверяет настройки при запуске и перечисляет сразу все найденные ошибки.
+
+	var problems []string
+
+	if cfg.VKToken == "" && cfg.TelegramToken == "" {
+		problems = append(problems, "vk_token (TSB_VK_TOKEN) or telegram_token (TSB_TELEGRAM_TOKEN) is required")
+	}
+	if u, err := url.Parse(cfg.TelegramAPIURL); cfg.TelegramToken != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		problems = append(problems, fmt.Sprintf("telegram_api_url (TSB_TELEGRAM_API_URL) %q is not an http(s) URL", cfg.TelegramAPIURL))
+	}
+	if cfg.AdminID < 0 {
+		problems = append(problems, "admin_id (TSB_ADMIN_ID) must not be negative")
+	}
+	if cfg.DBPath == "" {
+		problems = append(problems, "db_path (TSB_DB_PATH) is required")
+	}
+	if cfg.GroupsDir == "" {
+		problems = append(problems, "groups_dir (TSB_GROUPS_DIR) is required")
+	}
+	if u, err := url.Parse(cfg.TimetableURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("timetable_url (TSB_TIMETABLE_URL) %q is not an http(s) URL", cfg.TimetableURL))
+	}
+	if cfg.CacheTTL <= 0 {
+		problems = append(problems, "cache_ttl (TSB_CACHE_TTL) must be positive")
+	}
+	// Время рассылки сравнивается с текущим в виде "15:04", поэтому "8:00" приводится к "08:00".
+	morning, evening := normalizeClock(cfg.MorningTime), normalizeClock(cfg.EveningTime)
+	if morning == "" {
+		problems = append(problems, fmt.Sprintf("morning_time (TSB_MORNING_TIME) %q is not HH:MM", cfg.MorningTime))
+	}
+	if evening == "" {
+		problems = append(problems, fmt.Sprintf("evening_time (TSB_EVENING_TIME) %q is not HH:MM", cfg.EveningTime))
+	}
+	if morning != "" && morning == evening {
+		problems = append(problems, "morning_time and evening_time must differ")
+	}
+	if cfg.ChangesInterval < 0 {
+		problems = append(problems, "changes_interval (TSB_CHANGES_INTERVAL) must not be negative")
+	}
+	if loc, err := time.LoadLocation(cfg.Timezone); err != nil {
+		problems = append(problems, fmt.Sprintf("timezone (TSB_TIMEZONE) %q is unknown", cfg.Timezone))
+	} else {
+		cfg.Location = loc
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		problems = append(problems, fmt.Sprintf("log_level (TSB_LOG_LEVEL) %q is not one of debug, info, warn, error", cfg.LogLevel))
+	}
+	if cfg.LogFormat != "text" && cfg.LogFormat != "json" {
+		problems = append(problems, fmt.Sprintf("log_format (TSB_LOG_FORMAT) %q is not text or json", cfg.LogFormat))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config:\n  %s", strings.Join(problems, "\n  "))
+	}
+	cfg.TimetableURL = strings.TrimRight(cfg.TimetableURL, "/")
+	cfg.MorningTime, cfg.EveningTime = morning, evening
+	return nil
+}
+
+func (cfg *Config) isOwner(user chatID) bool {
+
+	// Функция isOwner() проверяет, является ли пользователь владельцем бота, указанным в настройках для своей платформы.
+
+	switch user.Platform {
+	case PLATFORM_VK:
+		return cfg.AdminID != 0 && user.ID == int64(cfg.AdminID)
+	case PLATFORM_TELEGRAM:
+		return cfg.TelegramAdminID != 0 && user.ID == cfg.TelegramAdminID
+	}
+	return false
+}
+
+func (cfg *Config) now() time.Time {
+
+	// Текущее время в часовом поясе бота, независимо от часового пояса сервера.
+
+	return time.Now().In(cfg.Location)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearConfigEnv сбрасывает переменные TSB_*, которые могли остаться в окружении, и подставляет пустой файл настроек,
+// чтобы тест видел только свои значения.
+func clearConfigEnv(t *testing.T) {
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); strings.HasPrefix(name, "TSB_") {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TSB_CONFIG", path)
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+
+	// Переменные окружения переопределяют файл настроек, а время рассылки приводится к виду ЧЧ:ММ.
+
+	clearConfigEnv(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("vk_token: from-file\nadmin_id: 1\nmorning_time: \"07:30\"\ncache_ttl: 10m\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TSB_CONFIG", path)
+	t.Setenv("TSB_ADMIN_ID", "42")
+	t.Setenv("TSB_TELEGRAM_TOKEN", "tg")
+	t.Setenv("TSB_TELEGRAM_ADMIN_ID", "100500")
+	t.Setenv("TSB_TIMETABLE_URL", "https://example.com/")
+	t.Setenv("TSB_MORNING_TIME", "8:00")
+	t.Setenv("TSB_EVENING_TIME", "21.15")
+	t.Setenv("TSB_CHANGES_INTERVAL", "0s")
+	t.Setenv("TSB_SUNDAY_WEEK_SCHEDULE", "false")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.VKToken != "from-file" || cfg.CacheTTL != 10*time.Minute {
+		t.Errorf("file values lost: vk_token = %q, cache_ttl = %s", cfg.VKToken, cfg.CacheTTL)
+	}
+	if cfg.AdminID != 42 || cfg.TelegramToken != "tg" || cfg.TelegramAdminID != 100500 {
+		t.Errorf("env values not applied: admin_id = %d, telegram_token = %q, telegram_admin_id = %d", cfg.AdminID, cfg.TelegramToken, cfg.TelegramAdminID)
+	}
+	if cfg.MorningTime != "08:00" || cfg.EveningTime != "21:15" {
+		t.Errorf("times = %q, %q, want 08:00, 21:15", cfg.MorningTime, cfg.EveningTime)
+	}
+	if cfg.TimetableURL != "https://example.com" {
+		t.Errorf("timetable_url = %q", cfg.TimetableURL)
+	}
+	if cfg.ChangesInterval != 0 || cfg.SundayWeekSchedule {
+		t.Errorf("changes_interval = %s, sunday_week_schedule = %v", cfg.ChangesInterval, cfg.SundayWeekSchedule)
+	}
+	if cfg.Location == nil || cfg.Location.String() != "Asia/Tomsk" {
+		t.Errorf("location = %v", cfg.Location)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"missing explicit file", map[string]string{"TSB_CONFIG": "/nonexistent/config.yaml"}, "config: "},
+		{"admin id", map[string]string{"TSB_ADMIN_ID": "admin"}, "TSB_ADMIN_ID"},
+		{"telegram admin id", map[string]string{"TSB_TELEGRAM_ADMIN_ID": "1.5"}, "TSB_TELEGRAM_ADMIN_ID"},
+		{"cache ttl", map[string]string{"TSB_CACHE_TTL": "30"}, "TSB_CACHE_TTL"},
+		{"changes interval", map[string]string{"TSB_CHANGES_INTERVAL": "hourly"}, "TSB_CHANGES_INTERVAL"},
+		{"sunday week schedule", map[string]string{"TSB_SUNDAY_WEEK_SCHEDULE": "yes please"}, "TSB_SUNDAY_WEEK_SCHEDULE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			t.Setenv("TSB_VK_TOKEN", "vk")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loadConfig() err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   string // "" - настройки верны.
+	}{
+		{"defaults", func(cfg *Config) {}, ""},
+		{"no tokens", func(cfg *Config) { cfg.VKToken = "" }, "vk_token (TSB_VK_TOKEN) or telegram_token"},
+		{"telegram url", func(cfg *Config) { cfg.TelegramToken, cfg.TelegramAPIURL = "tg", "api.telegram.org" }, "telegram_api_url"},
+		{"negative admin", func(cfg *Config) { cfg.AdminID = -1 }, "admin_id"},
+		{"no db path", func(cfg *Config) { cfg.DBPath = "" }, "db_path"},
+		{"no groups dir", func(cfg *Config) { cfg.GroupsDir = "" }, "groups_dir"},
+		{"timetable url", func(cfg *Config) { cfg.TimetableURL = "ftp://timetable.tusur.ru" }, "timetable_url"},
+		{"cache ttl", func(cfg *Config) { cfg.CacheTTL = 0 }, "cache_ttl"},
+		{"morning time", func(cfg *Config) { cfg.MorningTime = "25:00" }, "morning_time (TSB_MORNING_TIME) \"25:00\""},
+		{"evening time", func(cfg *Config) { cfg.EveningTime = "8 pm" }, "evening_time (TSB_EVENING_TIME) \"8 pm\""},
+		{"same times", func(cfg *Config) { cfg.MorningTime, cfg.EveningTime = "8:00", "08.00" }, "must differ"},
+		{"changes interval", func(cfg *Config) { cfg.ChangesInterval = -time.Minute }, "changes_interval"},
+		{"timezone", func(cfg *Config) { cfg.Timezone = "Mars/Olympus" }, "timezone"},
+		{"log level", func(cfg *Config) { cfg.LogLevel = "verbose" }, "log_level"},
+		{"log format", func(cfg *Config) { cfg.LogFormat = "xml" }, "log_format"},
+	}
+
+	for _, tt := range tests {
+		cfg := defaultConfig()
+		cfg.VKToken = "vk"
+		tt.modify(&cfg)
+
+		err := cfg.validate()
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: validate() err = %v", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: validate() err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	// Все ошибки перечисляются сразу, а не по одной.
+	cfg := Config{}
+	err := cfg.validate()
+	if err == nil || strings.Count(err.Error(), "\n  ") < 5 {
+		t.Errorf("validate() of empty config err = %v", err)
+	}
+}
@@ -1,3 +1,4 @@
 package main
 
-const TOKEN = "INSERT_VK_TOKEN_HERE"
+// Максимальная длина сообщения ВК. Более длинные ответы делятся на несколько сообщений.
+const MESSAGE_LIMIT = 4096
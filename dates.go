@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Месяц, с которого даты без года относятся к следующему учебному году. Учебный год начинается в сентябре,
+// но после летней сессии студентов интересует уже осенний семестр, поэтому граница проходит по 1 августа.
+const ACADEMIC_YEAR_START = time.August
+
+// На сколько дней можно запросить расписание одной командой. Больше месяца в чат все равно не поместится.
+const MAX_PERIOD_DAYS = 31
+
+// Дата в виде "15.10", "15.10.26" или "15.10.2026".
+var numericDateRe = regexp.MustCompile(`^(\d{1,2})\.(\d{1,2})(?:\.(\d{2}|\d{4}))?$`)
+
+var weekdayNames = map[string]time.Weekday{
+	"понедельник": time.Monday, "пн": time.Monday,
+	"вторник": time.Tuesday, "вт": time.Tuesday,
+	"среда": time.Wednesday, "среду": time.Wednesday, "ср": time.Wednesday,
+	"четверг": time.Thursday, "чт": time.Thursday,
+	"пятница": time.Friday, "пятницу": time.Friday, "пт": time.Friday,
+	"суббота": time.Saturday, "субботу": time.Saturday, "сб": time.Saturday,
+	"воскресенье": time.Sunday, "вс": time.Sunday,
+}
+
+// Названия месяцев различаются по первым трем буквам: "октября", "окт" и "октябрь" - октябрь.
+var monthPrefixes = map[string]time.Month{
+	"янв": time.January, "фев": time.February, "мар": time.March, "апр": time.April,
+	"мая": time.May, "май": time.May, "июн": time.June, "июл": time.July, "авг": time.August,
+	"сен": time.September, "окт": time.October, "ноя": time.November, "дек": time.December,
+}
+
+var relativeDays = map[string]int{
+	"позавчера": -2, "вчера": -1, "сегодня": 0, "завтра": 1, "послезавтра": 2,
+}
+
+var (
+	nextWords       = []string{"следующий", "следующую", "следующее", "следующая", "следующей", "след"}
+	thisWords       = []string{"этот", "эту", "это", "эта", "этой"}
+	weekWords       = []string{"неделя", "неделю", "неделе"}
+	prepositions    = []string{"на", "в", "во", "с", "к"}
+	rangeSeparators = []string{"-", "—", "по", "до"}
+)
+
+// Промежуток дат, названный в команде. Для одного дня from и to совпадают.
+type dateRange struct {
+	from time.Time
+	to   time.Time
+}
+
+func (d dateRange) single() bool {
+	return d.from.Equal(d.to)
+}
+
+func (d dateRange) day(now time.Time) time.Time {
+
+	// Функция day() возвращает день для команд, которые работают с одним днем: первый день промежутка,
+	// а если дата в команде не указана - сегодняшний.
+
+	if d.from.IsZero() {
+		return startOfDay(now)
+	}
+	return d.from
+}
+
+func oneOf(word string, words []string) bool {
+	for _, w := range words {
+		if word == w {
+			return true
+		}
+	}
+	return false
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func inferYear(now time.Time, month time.Month) int {
+
+	// Функция inferYear() выбирает год для даты без года так, чтобы она попала в текущий учебный год:
+	// в октябре "15.01" - январь следующего года, а в феврале "15.10" - октябрь прошлого.
+
+	start := now.Year()
+	if now.Month() < ACADEMIC_YEAR_START {
+		start--
+	}
+	if month >= ACADEMIC_YEAR_START {
+		return start
+	}
+	return start + 1
+}
+
+func makeDate(now time.Time, day int, month time.Month, year int) (time.Time, bool) {
+
+	// Функция makeDate() собирает дату, проверяя, что такой день существует: time.Date превратил бы 31.02 в 03.03.
+
+	if year == 0 {
+		year = inferYear(now, month)
+	}
+	date := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	return date, date.Day() == day && date.Month() == month
+}
+
+func parseDayNumber(value string) (int, bool) {
+	n, err := strconv.Atoi(value)
+	return n, err == nil && n >= 1 && n <= 31
+}
+
+func parseMonth(word string) (time.Month, bool) {
+	runes := []rune(word)
+	if len(runes) < 3 {
+		return 0, false
+	}
+	month, ok := monthPrefixes[string(runes[:3])]
+	return month, ok
+}
+
+func parseNumericDate(token string, now time.Time) (time.Time, bool, error) {
+
+	// Функция parseNumericDate() разбирает дату вида дд.мм[.гг]. Если токен похож на дату,
+	// но такой даты нет (например, 31.02), возвращается ошибка.
+
+	m := numericDateRe.FindStringSubmatch(token)
+	if m == nil {
+		return time.Time{}, false, nil
+	}
+
+	day, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	year, _ := strconv.Atoi(m[3])
+	if len(m[3]) == 2 {
+		year += 2000
+	}
+	if month < 1 || month > 12 {
+		return time.Time{}, true, fmt.Errorf("%w: %q", errBadDate, token)
+	}
+
+	date, ok := makeDate(now, day, time.Month(month), year)
+	if !ok {
+		return time.Time{}, true, fmt.Errorf("%w: %q", errBadDate, token)
+	}
+	return date, true, nil
+}
+
+func weekdayAfter(from time.Time, weekday time.Weekday) time.Time {
+
+	// Функция weekdayAfter() возвращает ближайший к from день недели weekday, считая и сам from.
+
+	return from.AddDate(0, 0, (int(weekday)-int(from.Weekday())+7)%7)
+}
+
+func parseDay(tokens []string, now time.Time) (time.Time, int, error) {
+
+	// Функция parseDay() разбирает один день в начале tokens и возвращает его вместе с числом использованных слов.
+	// Понимаются выражения "сегодня", "послезавтра", "пятница", "следующую среду", "через 3 дня", "через неделю",
+	// "15 октября" и даты вида дд.мм[.гг]. Если день не найден, возвращается 0 слов.
+
+	today := startOfDay(now)
+	first := tokens[0]
+
+	if offset, ok := relativeDays[first]; ok {
+		return today.AddDate(0, 0, offset), 1, nil
+	}
+
+	// "В пятницу" - ближайшая пятница, если сегодня пятница - то сегодня.
+	if weekday, ok := weekdayNames[first]; ok {
+		return weekdayAfter(today, weekday), 1, nil
+	}
+
+	// "В следующую среду" - среда следующей недели, даже если ближайшая среда еще не прошла.
+	if oneOf(first, nextWords) && len(tokens) > 1 {
+		if weekday, ok := weekdayNames[tokens[1]]; ok {
+			return weekdayAfter(getWeekStart(today).AddDate(0, 0, 7), weekday), 2, nil
+		}
+	}
+
+	if first == "через" && len(tokens) > 1 {
+		if oneOf(tokens[1], weekWords) {
+			return today.AddDate(0, 0, 7), 2, nil
+		}
+		if n, err := strconv.Atoi(tokens[1]); err == nil && n >= 0 && len(tokens) > 2 {
+			switch {
+			case strings.HasPrefix(tokens[2], "д"):
+				return today.AddDate(0, 0, n), 3, nil
+			case strings.HasPrefix(tokens[2], "недел"):
+				return today.AddDate(0, 0, 7*n), 3, nil
+			}
+		}
+	}
+
+	if date, ok, err := parseNumericDate(first, now); ok {
+		return date, 1, err
+	}
+
+	// "15 октября", "15 окт 2026".
+	if day, ok := parseDayNumber(first); ok && len(tokens) > 1 {
+		if month, ok := parseMonth(tokens[1]); ok {
+			used, year := 2, 0
+			if len(tokens) > 2 && len(tokens[2]) == 4 {
+				if y, err := strconv.Atoi(tokens[2]); err == nil {
+					used, year = 3, y
+				}
+			}
+			date, ok := makeDate(now, day, month, year)
+			if !ok {
+				return date, used, fmt.Errorf("%w: %q", errBadDate, strings.Join(tokens[:used], " "))
+			}
+			return date, used, nil
+		}
+	}
+	return time.Time{}, 0, nil
+}
+
+func parseWeek(tokens []string, now time.Time) (dateRange, int) {
+
+	// Функция parseWeek() разбирает "неделю", "эту неделю" и "следующую неделю" в начале tokens.
+	// Неделя считается с понедельника по субботу, в воскресенье текущей считается следующая неделя.
+
+	used, next := 0, false
+	switch {
+	case oneOf(tokens[0], weekWords):
+		used = 1
+	case len(tokens) > 1 && oneOf(tokens[1], weekWords) && oneOf(tokens[0], thisWords):
+		used = 2
+	case len(tokens) > 1 && oneOf(tokens[1], weekWords) && oneOf(tokens[0], nextWords):
+		used, next = 2, true
+	default:
+		return dateRange{}, 0
+	}
+
+	today := startOfDay(now)
+	monday := getWeekStart(today)
+	if next || isSunday(today) {
+		monday = monday.AddDate(0, 0, 7)
+	}
+	return dateRange{from: monday, to: monday.AddDate(0, 0, 5)}, used
+}
+
+func parseDateExpr(tokens []string, now time.Time) (dateRange, int, error) {
+
+	// Функция parseDateExpr() разбирает день или промежуток дней в начале tokens: "завтра", "на следующей неделе",
+	// "15.10-20.10", "с 15 октября по 20 октября". Предлог перед выражением считается его частью.
+
+	skip := 0
+	if oneOf(tokens[0], prepositions) && len(tokens) > 1 {
+		skip = 1
+	}
+	tokens = tokens[skip:]
+
+	if week, used := parseWeek(tokens, now); used > 0 {
+		return week, skip + used, nil
+	}
+
+	// Промежуток, записанный одним словом: "15.10-20.10".
+	if parts := strings.SplitN(tokens[0], "-", 2); len(parts) == 2 && numericDateRe.MatchString(parts[0]) {
+		from, _, err := parseNumericDate(parts[0], now)
+		if err != nil {
+			return dateRange{}, skip + 1, err
+		}
+		to, ok, err := parseNumericDate(parts[1], now)
+		if !ok || err != nil {
+			return dateRange{}, skip + 1, fmt.Errorf("%w: %q", errBadDate, tokens[0])
+		}
+		r, err := orderedRange(from, to, tokens[0])
+		return r, skip + 1, err
+	}
+
+	from, used, err := parseDay(tokens, now)
+	if used == 0 {
+		return dateRange{}, 0, nil
+	}
+	if err != nil {
+		return dateRange{}, skip + used, err
+	}
+
+	// Второй день промежутка: "15.10 - 20.10", "с 15.10 по 20.10".
+	if rest := tokens[used:]; len(rest) > 1 && oneOf(rest[0], rangeSeparators) {
+		to, toUsed, err := parseDay(rest[1:], now)
+		if toUsed > 0 {
+			if err != nil {
+				return dateRange{}, skip + used + 1 + toUsed, err
+			}
+			r, err := orderedRange(from, to, strings.Join(tokens[:used+1+toUsed], " "))
+			return r, skip + used + 1 + toUsed, err
+		}
+	}
+	return dateRange{from: from, to: from}, skip + used, nil
+}
+
+func orderedRange(from time.Time, to time.Time, expr string) (dateRange, error) {
+	if to.Before(from) {
+		return dateRange{}, fmt.Errorf("%w: %q ends before it starts", errBadDate, expr)
+	}
+	return dateRange{from: from, to: to}, nil
+}
+
+func parseDates(fields []string, now time.Time) (dateRange, []string, error) {
+
+	// Функция parseDates() ищет среди аргументов команды день или промежуток дней и возвращает его
+	// вместе с остальными аргументами в исходном виде. Если дата не указана, from и to нулевые.
+	// Если дата указана с ошибкой, например 31.02, возвращается ошибка errBadDate.
+
+	tokens := make([]string, len(fields))
+	for i, f := range fields {
+		tokens[i] = strings.Trim(strings.ToLower(f), ",.!?;")
+	}
+
+	var found dateRange
+	var rest []string
+	for i := 0; i < len(tokens); i++ {
+		if found.from.IsZero() && tokens[i] != "" {
+			r, used, err := parseDateExpr(tokens[i:], now)
+			if err != nil {
+				return dateRange{}, fields, err
+			}
+			if used > 0 {
+				found = r
+				i += used - 1
+				continue
+			}
+		}
+		rest = append(rest, fields[i])
+	}
+	return found, rest, nil
+}
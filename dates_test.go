@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testDate(value string) time.Time {
+	date, err := time.ParseInLocation("02.01.2006", value, scheduleLocation)
+	if err != nil {
+		panic(err)
+	}
+	return date
+}
+
+func TestInferYear(t *testing.T) {
+
+	// Учебный год начинается 1 августа: до этого даты с августа по декабрь относятся к прошлому году,
+	// а с этого дня даты с января по июль - к следующему.
+
+	tests := []struct {
+		now   string
+		month time.Month
+		want  int
+	}{
+		{"31.07.2026", time.September, 2025},
+		{"31.07.2026", time.August, 2025},
+		{"31.07.2026", time.July, 2026},
+		{"31.07.2026", time.January, 2026},
+		{"01.08.2026", time.August, 2026},
+		{"01.08.2026", time.September, 2026},
+		{"01.08.2026", time.July, 2027},
+		{"01.08.2026", time.January, 2027},
+		{"31.12.2026", time.December, 2026},
+		{"31.12.2026", time.January, 2027},
+		{"01.01.2027", time.December, 2026},
+		{"01.01.2027", time.January, 2027},
+		{"15.02.2027", time.October, 2026},
+		{"15.02.2027", time.June, 2027},
+	}
+
+	for _, tt := range tests {
+		if got := inferYear(testDate(tt.now), tt.month); got != tt.want {
+			t.Errorf("inferYear(%s, %s) = %d, want %d", tt.now, tt.month, got, tt.want)
+		}
+	}
+}
+
+func TestParseDates(t *testing.T) {
+	tests := []struct {
+		now     string
+		text    string
+		from    string // "" - дата в тексте не найдена.
+		to      string // "" - совпадает с from.
+		rest    string
+		wantErr bool
+	}{
+		// Без даты аргументы возвращаются как есть.
+		{now: "14.10.2026", text: "431-2", rest: "431-2"},
+		{now: "14.10.2026", text: "", rest: ""},
+
+		// Относительные дни. 14.10.2026 - среда.
+		{now: "14.10.2026", text: "сегодня", from: "14.10.2026"},
+		{now: "14.10.2026", text: "завтра 431-2", from: "15.10.2026", rest: "431-2"},
+		{now: "14.10.2026", text: "431-2 на послезавтра", from: "16.10.2026", rest: "431-2"},
+		{now: "14.10.2026", text: "вчера", from: "13.10.2026"},
+		{now: "31.12.2026", text: "завтра", from: "01.01.2027"},
+
+		// Дни недели: ближайший, считая сегодня, и "следующий" - на следующей неделе.
+		{now: "14.10.2026", text: "в среду", from: "14.10.2026"},
+		{now: "14.10.2026", text: "в пятницу", from: "16.10.2026"},
+		{now: "14.10.2026", text: "в понедельник", from: "19.10.2026"},
+		{now: "14.10.2026", text: "следующую среду", from: "21.10.2026"},
+		{now: "14.10.2026", text: "в следующую среду", from: "21.10.2026"},
+		{now: "14.10.2026", text: "в следующий понедельник", from: "19.10.2026"},
+		{now: "12.10.2026", text: "в следующую пятницу", from: "23.10.2026"},
+
+		// "Через N дней" и "через N недель", в том числе через границу года.
+		{now: "14.10.2026", text: "через 3 дня", from: "17.10.2026"},
+		{now: "30.12.2026", text: "через 3 дня", from: "02.01.2027"},
+		{now: "31.12.2026", text: "через 1 день", from: "01.01.2027"},
+		{now: "25.12.2026", text: "через неделю", from: "01.01.2027"},
+		{now: "25.12.2026", text: "через 2 недели", from: "08.01.2027"},
+		{now: "14.10.2026", text: "через 0 дней", from: "14.10.2026"},
+
+		// Даты без года выбираются в текущем учебном году.
+		{now: "14.10.2026", text: "15.10", from: "15.10.2026"},
+		{now: "14.10.2026", text: "15.01", from: "15.01.2027"},
+		{now: "15.02.2027", text: "15.10", from: "15.10.2026"},
+		{now: "31.07.2026", text: "01.09", from: "01.09.2025"},
+		{now: "01.08.2026", text: "01.09", from: "01.09.2026"},
+		{now: "01.08.2026", text: "15.07", from: "15.07.2027"},
+		{now: "14.10.2026", text: "15 октября", from: "15.10.2026"},
+		{now: "14.10.2026", text: "15 янв", from: "15.01.2027"},
+
+		// Год, указанный явно.
+		{now: "14.10.2026", text: "15.10.25", from: "15.10.2025"},
+		{now: "14.10.2026", text: "15.10.2028", from: "15.10.2028"},
+		{now: "14.10.2026", text: "15 октября 2028 431-2", from: "15.10.2028", rest: "431-2"},
+
+		// Несуществующие даты.
+		{now: "14.10.2026", text: "31.02", wantErr: true},
+		{now: "14.10.2026", text: "30.02.2028", wantErr: true},
+		{now: "14.10.2026", text: "29.02", wantErr: true},
+		{now: "14.10.2027", text: "29.02", from: "29.02.2028"},
+		{now: "14.10.2026", text: "31 апреля", wantErr: true},
+		{now: "14.10.2026", text: "32.10", wantErr: true},
+		{now: "14.10.2026", text: "15.13", wantErr: true},
+		{now: "14.10.2026", text: "00.10", wantErr: true},
+
+		// Промежутки.
+		{now: "14.10.2026", text: "15.10-20.10", from: "15.10.2026", to: "20.10.2026"},
+		{now: "14.10.2026", text: "с 15.10 по 20.10", from: "15.10.2026", to: "20.10.2026"},
+		{now: "14.10.2026", text: "с 15 октября по 20 октября 431-2", from: "15.10.2026", to: "20.10.2026", rest: "431-2"},
+		{now: "14.10.2026", text: "28.12-03.01", from: "28.12.2026", to: "03.01.2027"},
+		{now: "14.10.2026", text: "20.10-15.10", wantErr: true},
+		{now: "14.10.2026", text: "с 20.10 по 15.10", wantErr: true},
+		{now: "14.10.2026", text: "с 20 октября до 15 октября", wantErr: true},
+		{now: "14.10.2026", text: "15.10-31.02", wantErr: true},
+		{now: "14.10.2026", text: "15.10-абв", wantErr: true},
+
+		// Недели с понедельника по субботу, в воскресенье - следующая.
+		{now: "14.10.2026", text: "на неделю", from: "12.10.2026", to: "17.10.2026"},
+		{now: "14.10.2026", text: "на эту неделю", from: "12.10.2026", to: "17.10.2026"},
+		{now: "14.10.2026", text: "на следующей неделе", from: "19.10.2026", to: "24.10.2026"},
+		{now: "18.10.2026", text: "на неделю", from: "19.10.2026", to: "24.10.2026"},
+		{now: "28.12.2026", text: "на следующую неделю", from: "04.01.2027", to: "09.01.2027"},
+
+		// Учитывается только первая дата, остальные слова возвращаются в исходном виде.
+		{now: "14.10.2026", text: "Завтра, 431-2", from: "15.10.2026", rest: "431-2"},
+		{now: "14.10.2026", text: "завтра послезавтра", from: "15.10.2026", rest: "послезавтра"},
+		{now: "14.10.2026", text: "на 431-2", rest: "на 431-2"},
+	}
+
+	for _, tt := range tests {
+		now := testDate(tt.now).Add(13 * time.Hour)
+		got, rest, err := parseDates(strings.Fields(tt.text), now)
+
+		if tt.wantErr {
+			if !errors.Is(err, errBadDate) {
+				t.Errorf("%s: parseDates(%q) err = %v, want errBadDate", tt.now, tt.text, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: parseDates(%q) err = %v", tt.now, tt.text, err)
+			continue
+		}
+
+		var want dateRange
+		if tt.from != "" {
+			want.from = testDate(tt.from)
+			want.to = want.from
+			if tt.to != "" {
+				want.to = testDate(tt.to)
+			}
+		}
+		if !got.from.Equal(want.from) || !got.to.Equal(want.to) {
+			t.Errorf("%s: parseDates(%q) = %v - %v, want %v - %v", tt.now, tt.text, got.from, got.to, want.from, want.to)
+		}
+		if !reflect.DeepEqual(rest, strings.Fields(tt.rest)) && (len(rest) != 0 || tt.rest != "") {
+			t.Errorf("%s: parseDates(%q) rest = %q, want %q", tt.now, tt.text, rest, tt.rest)
+		}
+	}
+}
@@ -2,78 +2,81 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 )
 
-func getBinding(db *sql.DB, conversationId int) (bool, string) {
+// Сколько групп можно привязать к одному чату. Больше не нужно даже потоковой беседе, а ежедневная рассылка остается читаемой.
+const MAX_CHAT_GROUPS = 5
 
-	// Функция для проверки наличия ассоциации группы с чатом ВК.
-	// Происходит поиск в таблице по ID чата ВК, если запись имеется, то возвращается положительный результат и номер группы,
-	// иначе, отрицательный рез-тат и пустая строка, вместо номера группы.
+func getChatGroups(db *sql.DB, chat chatID) ([]string, error) {
 
-	var dbAnswer string
-	db.QueryRow("select groupNumber from binds where groupid = ?", conversationId).Scan(&dbAnswer)
+	// Функция getChatGroups() возвращает группы, ассоциированные с чатом, в порядке привязки.
+	// Пустой результат означает, что ассоциаций у чата нет. Ошибка возвращается только при сбое БД.
 
-	if dbAnswer == "" {
-		// Если dbAnswer пуста, значит, ассоциация пока не была создана.
-		return false, ""
-	} else {
-		// Иначе, ассоциация имеется.
-		return true, dbAnswer
+	rows, err := db.Query("select group_number from binds where platform = ? and peer_id = ? order by id", chat.Platform, chat.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get bindings of %s: %w", chat, err)
 	}
-}
+	defer rows.Close()
 
-func setBinding(db *sql.DB, conversationId int, groupNumber string) bool {
+	var groups []string
+	for rows.Next() {
+		var groupNumber string
+		if err = rows.Scan(&groupNumber); err != nil {
+			return nil, fmt.Errorf("get bindings of %s: %w", chat, err)
+		}
+		groups = append(groups, groupNumber)
+	}
+	return groups, rows.Err()
+}
 
-	// Функция для установки ассоциации группы с чатом ВК.
-	// Таблица с ассоциациями дополняется новой парой "ID чата - номер группы"
+func addBinding(db *sql.DB, chat chatID, groupNumber string) (bool, error) {
 
-	sqlStatement := "insert into binds(groupId, groupNumber) values (?, ?);"
-	stmt, _ := db.Prepare(sqlStatement)
-	_, err := stmt.Exec(conversationId, groupNumber)
-	stmt.Close()
+	// Функция для добавления ассоциации группы с чатом.
+	// Таблица с ассоциациями дополняется новой парой "чат - номер группы". Если такая пара уже есть,
+	// ничего не меняется и возвращается false.
 
-	// Проверяется наличие информации в переменной err.
+	sqlStatement := "insert into binds(platform, peer_id, group_number) values (?, ?, ?) on conflict(platform, peer_id, group_number) do nothing;"
+	res, err := db.Exec(sqlStatement, chat.Platform, chat.ID, groupNumber)
 	if err != nil {
 		// Если err не пуста, значит что-то пошло не так, и пара не была добавлена.
-		return false
-	} else {
-		// Иначе, пара добавлена, функция возвращает положительный ответ.
-		return true
+		return false, fmt.Errorf("add binding of %s: %w", chat, err)
 	}
+	n, err := res.RowsAffected()
+	return n > 0, err
 }
 
-func rmBinding(db *sql.DB, conversationId int) bool {
-
-	// Функция для удаления ассоциации группы с чатом ВК.
-	// В таблице ассоциаций проходит поиск по ID чата, если таковой найден - строка удаляется.
+func rmBinding(db *sql.DB, chat chatID, groupNumber string) (bool, error) {
 
-	sqlStatement := "DELETE FROM binds WHERE groupId = ?;"
-	stmt, _ := db.Prepare(sqlStatement)
-	_, err := stmt.Exec(conversationId)
-	stmt.Close()
+	// Функция для удаления ассоциации группы с чатом.
+	// В таблице ассоциаций проходит поиск по чату и группе, если таковой найден - строка удаляется.
+	// Пустой groupNumber удаляет все ассоциации чата. Возвращается, было ли что удалять.
 
-	// Проверяется наличие информации в переменной err.
+	sqlStatement := "DELETE FROM binds WHERE platform = ? AND peer_id = ? AND (? = '' OR group_number = ?);"
+	res, err := db.Exec(sqlStatement, chat.Platform, chat.ID, groupNumber, groupNumber)
 	if err != nil {
 		// Если err не пуста, значит что-то пошло не так, и пара не была удалена.
-		return false
-	} else {
-		// Иначе, пара удалена, функция возвращает положительный ответ.
-		return true
+		return false, fmt.Errorf("remove binding of %s: %w", chat, err)
 	}
+	n, err := res.RowsAffected()
+	return n > 0, err
 }
 
-func getBindingsInfo(db *sql.DB) string {
+func getBindingsInfo(db *sql.DB) (string, error) {
 
 	// Функция getBindingsInfo() отвечает за формирование сообщения со всеми ассоциациями.
 
 	var message string
-	var groupId string
+	var chat chatID
 	var groupNumber string
 	var counter = 0
 
-	// Выражение, для получения всех столбцов БД
-	rows, _ := db.Query("select groupID, groupNumber from binds")
+	// Выражение, для получения всех ассоциаций
+	rows, err := db.Query("select platform, peer_id, group_number from binds order by id")
+	if err != nil {
+		return "", err
+	}
 
 	message = "Актуальные ассоциации в БД:\n"
 
@@ -82,8 +85,497 @@ func getBindingsInfo(db *sql.DB) string {
 
 		counter++
 
-		rows.Scan(&groupId, &groupNumber)
-		message += fmt.Sprintf("%d. Чат %s - группа %s\n", counter, groupId, groupNumber)
+		if err = rows.Scan(&chat.Platform, &chat.ID, &groupNumber); err != nil {
+			return "", err
+		}
+		message += fmt.Sprintf("%d. Чат %s - группа %s\n", counter, chat, groupNumber)
+	}
+	return message, rows.Err()
+}
+
+// Время рассылки расписания в чат: в time (ЧЧ:ММ) отправляется расписание на день day.
+type notifySlot struct {
+	Time string
+	Day  string
+}
+
+// Значения day в таблице notifications. Строка со значением "off" означает, что рассылка в чат отключена.
+const NOTIFY_TODAY = "today"
+const NOTIFY_TOMORROW = "tomorrow"
+const NOTIFY_OFF = "off"
+
+// Запланированная отправка расписания группы в чат.
+type delivery struct {
+	chat        chatID
+	groupNumber string
+	day         string
+}
+
+func getNotifySlots(db *sql.DB, chat chatID) ([]notifySlot, error) {
+
+	// Функция getNotifySlots() возвращает настроенное время рассылки расписания в чат, отсортированное по времени.
+	// Пустой результат означает, что чат получает расписание в стандартное время.
+
+	rows, err := db.Query("select time, day from notifications where platform = ? and peer_id = ? order by time", chat.Platform, chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slots []notifySlot
+	for rows.Next() {
+		var slot notifySlot
+		if err = rows.Scan(&slot.Time, &slot.Day); err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+	return slots, rows.Err()
+}
+
+func setNotifySlot(db *sql.DB, chat chatID, slot notifySlot) error {
+
+	// Функция setNotifySlot() добавляет время рассылки в чат или меняет день для уже существующего времени.
+	// Отметка об отключенной рассылке при этом удаляется.
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("delete from notifications where platform = ? and peer_id = ? and day = ?", chat.Platform, chat.ID, NOTIFY_OFF); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`insert into notifications(platform, peer_id, time, day) values (?, ?, ?, ?)
+		on conflict(platform, peer_id, time) do update set day = excluded.day`, chat.Platform, chat.ID, slot.Time, slot.Day)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func rmNotifySlot(db *sql.DB, chat chatID, time string) (bool, error) {
+	res, err := db.Exec("delete from notifications where platform = ? and peer_id = ? and time = ? and day != ?", chat.Platform, chat.ID, time, NOTIFY_OFF)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func resetNotifySlots(db *sql.DB, chat chatID, off bool) error {
+
+	// Функция resetNotifySlots() удаляет все настройки времени рассылки чата, возвращая стандартное время,
+	// а при off = true - отключает рассылку в чат полностью.
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("delete from notifications where platform = ? and peer_id = ?", chat.Platform, chat.ID); err != nil {
+		return err
+	}
+	if off {
+		if _, err = tx.Exec("insert into notifications(platform, peer_id, time, day) values (?, ?, '', ?)", chat.Platform, chat.ID, NOTIFY_OFF); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func setNotifySlots(db *sql.DB, chat chatID, slots []notifySlot) error {
+
+	// Функция setNotifySlots() заменяет все время рассылки чата на slots. Пустой список выключает рассылку.
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("delete from notifications where platform = ? and peer_id = ?", chat.Platform, chat.ID); err != nil {
+		return err
+	}
+	if len(slots) == 0 {
+		slots = []notifySlot{{"", NOTIFY_OFF}}
+	}
+	for _, slot := range slots {
+		if _, err = tx.Exec("insert into notifications(platform, peer_id, time, day) values (?, ?, ?, ?)", chat.Platform, chat.ID, slot.Time, slot.Day); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func getDueDeliveries(db *sql.DB, cfg *Config, slot string) ([]delivery, error) {
+
+	// Функция getDueDeliveries() возвращает все отправки расписания, запланированные на время slot (ЧЧ:ММ).
+	// Чаты с собственными настройками получают расписание в указанное ими время,
+	// остальные - в стандартное время из настроек бота: утром на сегодня, вечером на завтра.
+
+	query := `select b.platform, b.peer_id, b.group_number, n.day from binds b
+		join notifications n on n.platform = b.platform and n.peer_id = b.peer_id where n.time = ?`
+	args := []interface{}{slot}
+
+	for _, def := range []notifySlot{{cfg.MorningTime, NOTIFY_TODAY}, {cfg.EveningTime, NOTIFY_TOMORROW}} {
+		if def.Time == slot {
+			query += ` union all select b.platform, b.peer_id, b.group_number, ? from binds b where not exists
+				(select 1 from notifications n where n.platform = b.platform and n.peer_id = b.peer_id)`
+			args = append(args, def.Day)
+		}
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []delivery
+	for rows.Next() {
+		var d delivery
+		if err = rows.Scan(&d.chat.Platform, &d.chat.ID, &d.groupNumber, &d.day); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func getChatSetting(db *sql.DB, chat chatID, name string, def string) (string, error) {
+
+	// Функция getChatSetting() возвращает значение настройки чата, а если она не задана - значение по умолчанию def.
+	// Ошибка чтения из БД возвращается вызывающему вместе со значением по умолчанию.
+
+	var value string
+	err := db.QueryRow("select value from chat_settings where platform = ? and peer_id = ? and name = ?", chat.Platform, chat.ID, name).Scan(&value)
+	if err == sql.ErrNoRows {
+		return def, nil
+	}
+	if err != nil {
+		return def, fmt.Errorf("get setting %s of %s: %w", name, chat, err)
+	}
+	return value, nil
+}
+
+func setChatSetting(db *sql.DB, chat chatID, name string, value string) error {
+	_, err := db.Exec(`insert into chat_settings(platform, peer_id, name, value) values (?, ?, ?, ?)
+		on conflict(platform, peer_id, name) do update set value = excluded.value, updated_at = current_timestamp`,
+		chat.Platform, chat.ID, name, value)
+	return err
+}
+
+func getBoundGroups(db *sql.DB) ([]string, error) {
+
+	// Функция getBoundGroups() возвращает номера всех групп, с которыми ассоциирован хотя бы один чат.
+
+	rows, err := db.Query("select distinct group_number from binds order by group_number")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []string
+	for rows.Next() {
+		var groupNumber string
+		if err = rows.Scan(&groupNumber); err != nil {
+			return nil, err
+		}
+		groups = append(groups, groupNumber)
+	}
+	return groups, rows.Err()
+}
+
+func getGroupChats(db *sql.DB, groupNumber string) ([]chatID, error) {
+
+	// Функция getGroupChats() возвращает все чаты, ассоциированные с группой.
+
+	rows, err := db.Query("select platform, peer_id from binds where group_number = ?", groupNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []chatID
+	for rows.Next() {
+		var chat chatID
+		if err = rows.Scan(&chat.Platform, &chat.ID); err != nil {
+			return nil, err
+		}
+		chats = append(chats, chat)
+	}
+	return chats, rows.Err()
+}
+
+func getScheduleSnapshot(db *sql.DB, groupNumber string) ([]Lesson, bool, error) {
+
+	// Функция getScheduleSnapshot() возвращает сохраненную версию расписания группы.
+	// Если группа еще не проверялась, возвращается отрицательный результат.
+
+	var data string
+	err := db.QueryRow("select lessons from schedule_snapshots where group_number = ?", groupNumber).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var lessons []Lesson
+	if err = json.Unmarshal([]byte(data), &lessons); err != nil {
+		return nil, false, err
+	}
+	return lessons, true, nil
+}
+
+func setScheduleSnapshot(db *sql.DB, groupNumber string, lessons []Lesson) error {
+	data, err := json.Marshal(lessons)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`insert into schedule_snapshots(group_number, lessons) values (?, ?)
+		on conflict(group_number) do update set lessons = excluded.lessons, updated_at = current_timestamp`, groupNumber, string(data))
+	return err
+}
+
+func addAuditRecord(db *sql.DB, chat chatID, user chatID, command string, args string, outcome string) error {
+
+	// Функция addAuditRecord() записывает вызов служебной команды в журнал действий вместе с его автором.
+
+	_, err := db.Exec("insert into audit_log(platform, peer_id, user_id, command, args, outcome) values (?, ?, ?, ?, ?, ?)",
+		chat.Platform, chat.ID, user.ID, command, args, outcome)
+	return err
+}
+
+// Администратор бота и его роль.
+type admin struct {
+	user      chatID
+	role      string
+	grantedBy string
+}
+
+func getRole(db *sql.DB, user chatID) (string, error) {
+
+	// Функция getRole() возвращает роль пользователя. Пустая строка означает, что пользователь не является администратором.
+
+	var role string
+	err := db.QueryRow("select role from admins where platform = ? and user_id = ?", user.Platform, user.ID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+func setRole(db *sql.DB, user chatID, role string, grantedBy chatID) error {
+	_, err := db.Exec(`insert into admins(platform, user_id, role, granted_by) values (?, ?, ?, ?)
+		on conflict(platform, user_id) do update set role = excluded.role, granted_by = excluded.granted_by`,
+		user.Platform, user.ID, role, grantedBy.String())
+	return err
+}
+
+func rmRole(db *sql.DB, user chatID) (bool, error) {
+	res, err := db.Exec("delete from admins where platform = ? and user_id = ?", user.Platform, user.ID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func getAdmins(db *sql.DB) ([]admin, error) {
+
+	// Функция getAdmins() возвращает всех администраторов бота из БД: сначала владельцев, затем модераторов.
+
+	rows, err := db.Query("select platform, user_id, role, granted_by from admins order by role = 'moderator', platform, user_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var admins []admin
+	for rows.Next() {
+		var a admin
+		if err = rows.Scan(&a.user.Platform, &a.user.ID, &a.role, &a.grantedBy); err != nil {
+			return nil, err
+		}
+		admins = append(admins, a)
+	}
+	return admins, rows.Err()
+}
+
+// Состояния рассылки.
+const BROADCAST_DRAFT = "draft"
+const BROADCAST_QUEUED = "queued"
+const BROADCAST_SENDING = "sending"
+const BROADCAST_DONE = "done"
+const BROADCAST_CANCELLED = "cancelled"
+const BROADCAST_INTERRUPTED = "interrupted"
+const BROADCAST_FAILED = "failed" // Рассылку не удалось начать, например не удалось получить список получателей.
+
+func createBroadcast(db *sql.DB, b broadcast) (int64, error) {
+	res, err := db.Exec(`insert into broadcasts(platform, peer_id, user_id, target_kind, target, text, status) values (?, ?, ?, ?, ?, ?, ?)`,
+		b.chat.Platform, b.chat.ID, b.user.ID, b.target.kind, b.target.value, b.text, BROADCAST_DRAFT)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func getBroadcast(db *sql.DB, id int64) (broadcast, bool, error) {
+	var b broadcast
+	err := db.QueryRow(`select id, platform, peer_id, user_id, target_kind, target, text, status, delivered, failed, blocked
+		from broadcasts where id = ?`, id).Scan(&b.id, &b.chat.Platform, &b.chat.ID, &b.user.ID, &b.target.kind, &b.target.value,
+		&b.text, &b.status, &b.delivered, &b.failed, &b.blocked)
+	if err == sql.ErrNoRows {
+		return b, false, nil
+	}
+	b.user.Platform = b.chat.Platform
+	return b, err == nil, err
+}
+
+func getLastDraft(db *sql.DB, user chatID) (int64, bool, error) {
+
+	// Функция getLastDraft() возвращает номер последнего неотправленного черновика рассылки пользователя.
+
+	var id int64
+	err := db.QueryRow("select id from broadcasts where platform = ? and user_id = ? and status = ? order by id desc limit 1",
+		user.Platform, user.ID, BROADCAST_DRAFT).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return id, err == nil, err
+}
+
+func setBroadcastStatus(db *sql.DB, id int64, from string, to string) (bool, error) {
+
+	// Функция setBroadcastStatus() переводит рассылку из состояния from в состояние to.
+	// Если рассылка уже в другом состоянии (например, ее подтвердили дважды), возвращается отрицательный результат.
+
+	res, err := db.Exec("update broadcasts set status = ?, updated_at = current_timestamp where id = ? and status = ?", to, id, from)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func finishBroadcast(db *sql.DB, b broadcast) error {
+	_, err := db.Exec(`update broadcasts set status = ?, delivered = ?, failed = ?, blocked = ?, updated_at = current_timestamp where id = ?`,
+		BROADCAST_DONE, b.delivered, b.failed, b.blocked, b.id)
+	return err
+}
+
+func interruptBroadcasts(db *sql.DB) ([]int64, error) {
+
+	// Функция interruptBroadcasts() вызывается при запуске бота: рассылки, которые отправлялись в момент остановки,
+	// помечаются прерванными, чтобы их не отправить повторно. Подтвержденные, но не начатые рассылки возвращаются для отправки.
+
+	if _, err := db.Exec("update broadcasts set status = ? where status = ?", BROADCAST_INTERRUPTED, BROADCAST_SENDING); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("select id from broadcasts where status = ? order by id", BROADCAST_QUEUED)
+	if err != nil {
+		return nil, err
 	}
-	return message
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func getBindings(db *sql.DB) (map[chatID][]string, error) {
+
+	// Функция getBindings() возвращает все чаты с ассоциациями и группы каждого чата.
+
+	rows, err := db.Query("select platform, peer_id, group_number from binds order by id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bindings := make(map[chatID][]string)
+	for rows.Next() {
+		var chat chatID
+		var groupNumber string
+		if err = rows.Scan(&chat.Platform, &chat.ID, &groupNumber); err != nil {
+			return nil, err
+		}
+		bindings[chat] = append(bindings[chat], groupNumber)
+	}
+	return bindings, rows.Err()
+}
+
+func getSettingDeliveries(db *sql.DB, name string, value string) ([]delivery, error) {
+
+	// Функция getSettingDeliveries() возвращает ассоциации чатов, у которых настройка name имеет значение value.
+
+	rows, err := db.Query(`select b.platform, b.peer_id, b.group_number from binds b
+		join chat_settings s on s.platform = b.platform and s.peer_id = b.peer_id where s.name = ? and s.value = ?`, name, value)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []delivery
+	for rows.Next() {
+		var d delivery
+		if err = rows.Scan(&d.chat.Platform, &d.chat.ID, &d.groupNumber); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func getLessonFilters(db *sql.DB, chat chatID) ([]lessonFilter, error) {
+
+	// Функция getLessonFilters() возвращает фильтры занятий чата в порядке добавления.
+
+	rows, err := db.Query("select id, action, field, pattern from lesson_filters where platform = ? and peer_id = ? order by id", chat.Platform, chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var filters []lessonFilter
+	for rows.Next() {
+		var f lessonFilter
+		if err = rows.Scan(&f.id, &f.action, &f.field, &f.pattern); err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, rows.Err()
+}
+
+func addLessonFilter(db *sql.DB, chat chatID, f lessonFilter) (bool, error) {
+
+	// Функция addLessonFilter() добавляет фильтр занятий чата. Если такой фильтр уже есть, возвращается false.
+
+	res, err := db.Exec(`insert into lesson_filters(platform, peer_id, action, field, pattern) values (?, ?, ?, ?, ?)
+		on conflict(platform, peer_id, action, field, pattern) do nothing`, chat.Platform, chat.ID, f.action, f.field, f.pattern)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func rmLessonFilters(db *sql.DB, chat chatID, id int64) error {
+
+	// Функция rmLessonFilters() удаляет фильтр чата с указанным id, а при id = 0 - все фильтры чата.
+
+	_, err := db.Exec("delete from lesson_filters where platform = ? and peer_id = ? and (? = 0 or id = ?)", chat.Platform, chat.ID, id, id)
+	return err
 }
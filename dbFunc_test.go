@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestGetDueDeliveries(t *testing.T) {
+
+	// Чаты без настроек получают расписание в стандартное время, чаты с настройками - только в свое время,
+	// а чаты с выключенной рассылкой не получают его вовсе.
+
+	db := newTestDB(t)
+	cfg := &Config{MorningTime: "08:00", EveningTime: "20:00", Location: scheduleLocation}
+
+	standard := chatID{PLATFORM_VK, 1}
+	custom := chatID{PLATFORM_VK, 2}
+	sameTime := chatID{PLATFORM_VK, 3}
+	off := chatID{PLATFORM_VK, 4}
+	twoGroups := chatID{PLATFORM_TELEGRAM, 5}
+
+	binds := []struct {
+		chat  chatID
+		group string
+	}{
+		{standard, "431-1"}, {custom, "431-2"}, {sameTime, "431-3"}, {off, "431-4"}, {twoGroups, "431-1"}, {twoGroups, "431-2"},
+	}
+	for _, b := range binds {
+		if _, err := addBinding(db, b.chat, b.group); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := setNotifySlots(db, custom, []notifySlot{{"07:00", NOTIFY_TODAY}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := setNotifySlots(db, sameTime, []notifySlot{{"08:00", NOTIFY_TOMORROW}, {"21:00", NOTIFY_TOMORROW}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := resetNotifySlots(db, off, true); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		slot string
+		want []string
+	}{
+		{"07:00", []string{"vk:2 431-2 today"}},
+		{"08:00", []string{"tg:5 431-1 today", "tg:5 431-2 today", "vk:1 431-1 today", "vk:3 431-3 tomorrow"}},
+		{"20:00", []string{"tg:5 431-1 tomorrow", "tg:5 431-2 tomorrow", "vk:1 431-1 tomorrow"}},
+		{"21:00", []string{"vk:3 431-3 tomorrow"}},
+		{"12:00", nil},
+	}
+
+	for _, tt := range tests {
+		deliveries, err := getDueDeliveries(db, cfg, tt.slot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, d := range deliveries {
+			got = append(got, fmt.Sprintf("%s %s %s", d.chat, d.groupNumber, d.day))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("getDueDeliveries(%q) = %q, want %q", tt.slot, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"runtime/debug"
+)
+
+// Ошибки получения расписания. Конкретная причина оборачивается в одну из них,
+// а обработчики команд по ним выбирают понятное пользователю сообщение.
+var (
+	errGroupNotFound    = errors.New("group not found")
+	errSiteUnavailable  = errors.New("timetable site unavailable")
+	errScheduleFormat   = errors.New("malformed schedule")
+	errLecturerNotFound = errors.New("lecturer not found")
+	errMessengerMissing = errors.New("no messenger for platform")
+
+	errChatAdminsUnavailable = errors.New("chat admins unavailable")
+	errChatBlocked           = errors.New("bot is blocked or removed from chat")
+	errBadDate               = errors.New("bad date")
+)
+
+func userError(err error) string {
+
+	// Функция userError() возвращает сообщение для пользователя, соответствующее ошибке.
+	// Ошибки, причина которых неизвестна, пользователю не расшифровываются.
+
+	switch {
+	case errors.Is(err, errGroupNotFound):
+		return groupUnavailableMsg
+	case errors.Is(err, errSiteUnavailable):
+		return siteUnavailableMsg
+	case errors.Is(err, errScheduleFormat):
+		return scheduleFormatMsg
+	case errors.Is(err, errChatAdminsUnavailable):
+		return chatAdminsUnavailableMsg
+	}
+	return unhandledErrMsg
+}
+
+func recoverPanic(where string) {
+
+	// Функция recoverPanic() вызывается через defer и не дает панике в одной задаче остановить весь бот.
+
+	if p := recover(); p != nil {
+		slog.Error(where+": panic", "panic", p, "stack", string(debug.Stack()))
+	}
+}
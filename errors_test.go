@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduleCacheErrors(t *testing.T) {
+
+	// Сбои сайта расписания превращаются в ошибки, по которым обработчик выбирает сообщение пользователю.
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing.ics":
+			http.NotFound(w, r)
+		case "/broken.ics":
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		case "/garbage.ics":
+			w.Write([]byte("<html>Технические работы</html>"))
+		default:
+			w.Write([]byte(testCalendarHeader + testCalendarFooter))
+		}
+	}))
+	defer server.Close()
+
+	// Сервер, который уже закрыт: соединение с ним не устанавливается.
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	cache := newScheduleCache(t.TempDir(), server.URL, time.Hour)
+	schedule := NewScheduleService(cache, newGroupDirectory(t.TempDir(), server.URL))
+
+	tests := []struct {
+		name string
+		url  string
+		want error
+	}{
+		{"missing", server.URL + "/missing.ics", errGroupNotFound},
+		{"broken", server.URL + "/broken.ics", errSiteUnavailable},
+		{"down", down.URL + "/down.ics", errSiteUnavailable},
+		{"garbage", server.URL + "/garbage.ics", errScheduleFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName, err := cache.getURL(tt.name, tt.url)
+			if err == nil {
+				_, err = schedule.parseFile(tt.name, fileName)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if userError(err) == unhandledErrMsg {
+				t.Errorf("no user message for %v", err)
+			}
+		})
+	}
+}
+
+func TestScheduleCacheStaleCopy(t *testing.T) {
+
+	// Если сайт недоступен, отдается последняя сохраненная копия расписания, даже устаревшая.
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	dir := t.TempDir()
+	cache := newScheduleCache(dir, down.URL, time.Hour)
+	if err := os.WriteFile(cache.path("431-2"), []byte(testCalendarHeader+testCalendarFooter), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.writeEntry("431-2", cacheEntry{FetchedAt: time.Now().Add(-2 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	fileName, err := cache.getURL("431-2", down.URL+"/431-2.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileName != cache.path("431-2") {
+		t.Errorf("fileName = %q, want %q", fileName, cache.path("431-2"))
+	}
+}
+
+func TestHandlerErrorReplies(t *testing.T) {
+
+	// Обработчики отвечают на сбой сайта и БД понятным сообщением, а не молчат и не падают.
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	schedule := newTestSchedule(t, map[string]string{"431-2": ""})
+	schedule.cache = newScheduleCache(t.TempDir(), server.URL, time.Hour)
+
+	// БД закрывается до обработки команды, поэтому любой запрос к ней завершается ошибкой.
+	brokenDB := newTestDB(t)
+	brokenDB.Close()
+
+	tests := []struct {
+		name string
+		text string
+		db   bool
+		want string
+	}{
+		{"site unavailable", "расписос 431-2", false, siteUnavailableMsg},
+		{"unknown group", "расписос 999-9", false, ""},
+		{"db for schedule", "расписос", true, unhandledErrMsg},
+		{"db for settings", "/settings", true, unhandledErrMsg},
+		{"db for exam reminders", "экзамены напоминания", true, unhandledErrMsg},
+	}
+
+	rt := newRouter(botCommands()...)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			if tt.db {
+				db = brokenDB
+			}
+			ms := newFakeMessenger()
+			r := &request{
+				chat:       chatID{PLATFORM_VK, 1},
+				user:       chatID{PLATFORM_VK, 1},
+				config:     &Config{Location: scheduleLocation},
+				db:         db,
+				messenger:  ms,
+				messengers: messengers{PLATFORM_VK: ms},
+				schedule:   schedule,
+			}
+
+			if !rt.dispatch(r, tt.text) {
+				t.Fatalf("%q is not a command", tt.text)
+			}
+			if r.outcome == "panic" {
+				t.Fatalf("%q panicked", tt.text)
+			}
+			reply := strings.Join(ms.messages(1), "\n")
+			if reply == "" {
+				t.Fatalf("no reply to %q", tt.text)
+			}
+			if tt.want != "" && !strings.Contains(reply, tt.want) {
+				t.Errorf("reply = %q, want %q", reply, tt.want)
+			}
+		})
+	}
+}
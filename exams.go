@@ -0,0 +1,123 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Настройка чата, включающая напоминания об экзаменах и зачетах: вечером накануне и утром в день экзамена.
+const SETTING_EXAM_REMINDERS = "exam_reminders"
+
+func isSessionLesson(l Lesson) bool {
+
+	// Занятия сессии: экзамены, зачеты и консультации перед ними.
+
+	return l.Kind == KindExam || l.Kind == KindCredit || l.Kind == KindConsultation
+}
+
+func upcomingExams(lessons []Lesson, now time.Time) []Lesson {
+
+	// Функция upcomingExams() возвращает еще не закончившиеся занятия сессии в порядке их начала.
+
+	var exams []Lesson
+	for _, l := range lessons {
+		if isSessionLesson(l) && l.End.After(now) {
+			exams = append(exams, l)
+		}
+	}
+	return exams
+}
+
+func daysUntil(now time.Time, t time.Time) int {
+
+	// Функция daysUntil() возвращает, через сколько календарных дней наступит t: 0 - сегодня, 1 - завтра.
+
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	t = t.In(now.Location())
+	to := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
+
+func formCountdown(days int) string {
+	switch days {
+	case 0:
+		return "сегодня"
+	case 1:
+		return "завтра"
+	}
+
+	// Склонение слова "день" после числа: 1 день, 2 дня, 5 дней, 21 день.
+	word := "дней"
+	if days%10 == 1 && days%100 != 11 {
+		word = "день"
+	} else if days%10 >= 2 && days%10 <= 4 && (days%100 < 12 || days%100 > 14) {
+		word = "дня"
+	}
+	return fmt.Sprintf("через %d %s", days, word)
+}
+
+func formExam(l Lesson, now time.Time) string {
+	var message = fmt.Sprintf("📝 %s\n", formLessonTitle(l))
+	if len(l.Teachers) > 0 {
+		message += fmt.Sprintf(" ‍👨 Преподаватель: %s\n", formTeachers(l.Teachers))
+	}
+	message += fmt.Sprintf(" 🏠 Аудитория: %s\n", formRooms(l.Rooms))
+	message += fmt.Sprintf(" 🕛 %s, %s\n\n", formLessonTime(l), formCountdown(daysUntil(now, l.Start)))
+	return message
+}
+
+func formExamsMessages(groupNumber string, now time.Time, exams []Lesson) []string {
+
+	// Функция formExamsMessages() формирует список предстоящих экзаменов, зачетов и консультаций группы
+	// с обратным отсчетом. Во время сессии список может не поместиться в одно сообщение.
+
+	if len(exams) == 0 {
+		return []string{fmt.Sprintf(noExamsMsg, groupNumber)}
+	}
+
+	blocks := []string{fmt.Sprintf("Сессия группы %s.\nВсего - %d.\n\n", groupNumber, len(exams))}
+	for _, l := range exams {
+		blocks = append(blocks, formExam(l, now))
+	}
+	return splitMessage(blocks, MESSAGE_LIMIT)
+}
+
+func sendExamReminders(db *sql.DB, ms messengers, schedule *ScheduleService, now time.Time, days int) {
+
+	// Функция sendExamReminders() напоминает чатам с включенными напоминаниями об экзаменах и зачетах,
+	// которые пройдут через days дней: 1 - вечерняя рассылка накануне, 0 - утренняя в день экзамена.
+	// Консультации не напоминаются, чтобы не дублировать напоминание об экзамене. Экзамены других подгрупп
+	// и скрытые фильтрами чата не напоминаются, как и не показываются в его расписании.
+
+	deliveries, err := getSettingDeliveries(db, SETTING_EXAM_REMINDERS, "on")
+	if err != nil {
+		slog.Error("exams: get reminder chats", "err", err)
+		return
+	}
+
+	day := now.AddDate(0, 0, days)
+	for _, d := range deliveries {
+		lessons, err := schedule.LessonsFor(d.groupNumber, day)
+		if err != nil {
+			slog.Error("exams: reminder", "chat", d.chat, "group", d.groupNumber, "err", err)
+			continue
+		}
+		lessons, _ = getScheduleView(db, d.chat).apply(lessons)
+
+		var message = ""
+		for _, l := range lessons {
+			if (l.Kind == KindExam || l.Kind == KindCredit) && l.Start.After(now) {
+				message += formExam(l, now)
+			}
+		}
+		if message == "" {
+			continue
+		}
+
+		if err = ms.send(d.chat, fmt.Sprintf(examReminderMsg, d.groupNumber, formCountdown(days))+message); err != nil {
+			slog.Error("exams: reminder failed", "chat", d.chat, "group", d.groupNumber, "err", err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendExamRemindersView(t *testing.T) {
+
+	// Напоминания об экзаменах учитывают подгруппу и фильтры чата, а если не осталось ни одного экзамена,
+	// напоминание не отправляется.
+
+	db := newTestDB(t)
+	ms := newFakeMessenger()
+	now := time.Date(2023, 1, 9, 19, 0, 0, 0, scheduleLocation)
+
+	schedule := newTestSchedule(t, map[string]string{"431-2": testCalendarHeader +
+		testEvent("20230110T084500", "20230110T121500", "Информатика (1 подгр.)", "Экзамен\\, Смирнов А.А.", "рк 418") +
+		testEvent("20230110T130000", "20230110T150000", "Физкультура", "Зачет\\, Петров П.П.", "спорткомплекс") +
+		testCalendarFooter})
+
+	all := chatID{PLATFORM_VK, 1}
+	secondSubgroup := chatID{PLATFORM_VK, 2}
+	nothingLeft := chatID{PLATFORM_VK, 3}
+	for _, chat := range []chatID{all, secondSubgroup, nothingLeft} {
+		if _, err := addBinding(db, chat, "431-2"); err != nil {
+			t.Fatal(err)
+		}
+		if err := setChatSetting(db, chat, SETTING_EXAM_REMINDERS, "on"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, chat := range []chatID{secondSubgroup, nothingLeft} {
+		if err := setChatSetting(db, chat, SETTING_SUBGROUP, "2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := addLessonFilter(db, nothingLeft, lessonFilter{action: FILTER_HIDE, field: FILTER_KIND, pattern: "зач"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sendExamReminders(db, messengers{PLATFORM_VK: ms}, schedule, now, 1)
+
+	tests := []struct {
+		chat chatID
+		want []string
+		skip []string
+	}{
+		{all, []string{"Информатика", "Физкультура"}, nil},
+		{secondSubgroup, []string{"Физкультура"}, []string{"Информатика"}},
+	}
+	for _, tt := range tests {
+		message := strings.Join(ms.messages(tt.chat.ID), "")
+		for _, s := range tt.want {
+			if !strings.Contains(message, s) {
+				t.Errorf("%s: %q not in %q", tt.chat, s, message)
+			}
+		}
+		for _, s := range tt.skip {
+			if strings.Contains(message, s) {
+				t.Errorf("%s: %q in %q", tt.chat, s, message)
+			}
+		}
+	}
+	if sent := ms.messages(nothingLeft.ID); len(sent) != 0 {
+		t.Errorf("%s: got %q, want nothing", nothingLeft, sent)
+	}
+}
+
+func TestHandleExamsView(t *testing.T) {
+
+	// Список экзаменов по команде учитывает подгруппу и фильтры чата так же, как напоминания.
+
+	db := newTestDB(t)
+	ms := newFakeMessenger()
+	day := time.Now().In(scheduleLocation).AddDate(0, 0, 3).Format("20060102")
+
+	schedule := newTestSchedule(t, map[string]string{"431-2": testCalendarHeader +
+		testEvent(day+"T084500", day+"T121500", "Информатика (1 подгр.)", "Экзамен\\, Смирнов А.А.", "рк 418") +
+		testEvent(day+"T130000", day+"T150000", "Физкультура", "Зачет\\, Петров П.П.", "спорткомплекс") +
+		testEvent(day+"T160000", day+"T170000", "История", "Консультация\\, Сидоров С.С.", "рк 101") +
+		testCalendarFooter})
+
+	all := chatID{PLATFORM_VK, 1}
+	filtered := chatID{PLATFORM_VK, 2}
+	for _, chat := range []chatID{all, filtered} {
+		if _, err := addBinding(db, chat, "431-2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := setChatSetting(db, filtered, SETTING_SUBGROUP, "2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := addLessonFilter(db, filtered, lessonFilter{action: FILTER_HIDE, field: FILTER_KIND, pattern: "консульт"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		chat chatID
+		want []string
+		skip []string
+	}{
+		{all, []string{"Всего - 3", "Информатика", "Физкультура", "История"}, nil},
+		{filtered, []string{"Всего - 1", "Физкультура"}, []string{"Информатика", "История"}},
+	}
+	rt := newRouter(botCommands()...)
+	for _, tt := range tests {
+		r := &request{
+			chat:      tt.chat,
+			user:      tt.chat,
+			config:    &Config{Location: scheduleLocation},
+			db:        db,
+			messenger: ms,
+			schedule:  schedule,
+		}
+		if !rt.dispatch(r, "экзамены") {
+			t.Fatal("экзамены is not dispatched")
+		}
+
+		message := strings.Join(ms.messages(tt.chat.ID), "")
+		for _, s := range tt.want {
+			if !strings.Contains(message, s) {
+				t.Errorf("%s: %q not in %q", tt.chat, s, message)
+			}
+		}
+		for _, s := range tt.skip {
+			if strings.Contains(message, s) {
+				t.Errorf("%s: %q in %q", tt.chat, s, message)
+			}
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Действия фильтров занятий. Скрывающий фильтр убирает подходящие занятия из расписания чата.
+// Если у чата есть показывающие фильтры, в расписании остаются только подходящие под них занятия,
+// причем такое занятие остается, даже если подходит и под скрывающий.
+const FILTER_HIDE = "hide"
+const FILTER_SHOW = "show"
+
+// Поля занятия, по которым работают фильтры.
+const FILTER_SUBJECT = "subject"
+const FILTER_KIND = "kind"
+const FILTER_TEACHER = "teacher"
+
+// Сколько фильтров может быть у одного чата. Этого хватает с запасом, а список остается читаемым.
+const MAX_CHAT_FILTERS = 20
+
+var filterActions = map[string]string{
+	"скрыть": FILTER_HIDE, "hide": FILTER_HIDE,
+	"показать": FILTER_SHOW, "show": FILTER_SHOW,
+}
+
+var filterFields = map[string]string{
+	"предмет": FILTER_SUBJECT, "subject": FILTER_SUBJECT,
+	"вид": FILTER_KIND, "тип": FILTER_KIND, "kind": FILTER_KIND, "type": FILTER_KIND,
+	"преподаватель": FILTER_TEACHER, "препод": FILTER_TEACHER, "teacher": FILTER_TEACHER,
+}
+
+// Фильтр занятий чата. pattern хранится в нижнем регистре и ищется в поле занятия как подстрока.
+type lessonFilter struct {
+	id      int64
+	action  string
+	field   string
+	pattern string
+}
+
+func (f lessonFilter) String() string {
+
+	// Фильтр записывается так же, как он добавляется командой /filter, чтобы его было легко повторить в другом чате.
+
+	var action, field = "скрыть", "предмет"
+	if f.action == FILTER_SHOW {
+		action = "показать"
+	}
+	switch f.field {
+	case FILTER_KIND:
+		field = "вид"
+	case FILTER_TEACHER:
+		field = "преподаватель"
+	}
+	return fmt.Sprintf("%s %s %s", action, field, f.pattern)
+}
+
+func (f lessonFilter) match(l Lesson) bool {
+	switch f.field {
+	case FILTER_SUBJECT:
+		return strings.Contains(strings.ToLower(l.Subject), f.pattern)
+	case FILTER_KIND:
+		// Вид проверяется и в записи календаря, и в сокращенном названии: "сам" найдет и "Самостоятельная работа", и "Сам.раб".
+		return strings.Contains(strings.ToLower(l.KindName), f.pattern) || strings.Contains(strings.ToLower(l.KindLabel()), f.pattern)
+	case FILTER_TEACHER:
+		for _, t := range l.Teachers {
+			if strings.Contains(strings.ToLower(t), f.pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchFilters(filters []lessonFilter, l Lesson) (bool, bool) {
+
+	// Функция matchFilters() проверяет занятие фильтрами чата и возвращает, скрыто ли оно и оставлено ли оно
+	// исключением, то есть подходит и под скрывающий, и под показывающий фильтр. Занятие скрывается,
+	// если оно не подходит ни под один показывающий фильтр и при этом подходит под скрывающий
+	// или у чата есть показывающие фильтры.
+
+	var hide, show, include bool
+	for _, f := range filters {
+		include = include || f.action == FILTER_SHOW
+		if f.match(l) {
+			hide = hide || f.action == FILTER_HIDE
+			show = show || f.action == FILTER_SHOW
+		}
+	}
+	return !show && (hide || include), hide && show
+}
+
+func parseLessonFilter(args []string) (lessonFilter, bool) {
+
+	// Функция parseLessonFilter() разбирает фильтр из аргументов команды: "скрыть вид сам.раб", "показать предмет физкультура".
+
+	if len(args) < 3 {
+		return lessonFilter{}, false
+	}
+	action, ok := filterActions[args[0]]
+	if !ok {
+		return lessonFilter{}, false
+	}
+	field, ok := filterFields[args[1]]
+	if !ok {
+		return lessonFilter{}, false
+	}
+	return lessonFilter{action: action, field: field, pattern: strings.Join(args[2:], " ")}, true
+}
+
+func handleFilter(r *request) {
+
+	// Команда /filter управляет фильтрами занятий чата:
+	// 	/filter - список фильтров;
+	// 	/filter скрыть/показать предмет/вид/преподаватель *текст* - добавить фильтр;
+	// 	/filter удалить *номер*, /filter сброс - удалить один или все фильтры;
+	// 	/filter проверка *дата* - какие занятия скрывают фильтры, по умолчанию - на этой неделе.
+	// Фильтры применяются ко всем сообщениям с расписанием чата, включая рассылку. Менять их в беседе могут только администраторы.
+
+	if len(r.args) == 0 {
+		sendFilters(r, "")
+		return
+	}
+	if r.args[0] == "проверка" || r.args[0] == "preview" {
+		previewFilters(r)
+		return
+	}
+
+	if !r.require(accessChatAdmin) {
+		return
+	}
+
+	filters, err := getLessonFilters(r.db, r.chat)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+
+	var message string
+	switch r.args[0] {
+	case "удалить", "delete":
+		n := 0
+		if len(r.args) > 1 {
+			n, _ = strconv.Atoi(r.args[1])
+		}
+		if n < 1 || n > len(filters) {
+			r.reply(filterUsage)
+			return
+		}
+		err = rmLessonFilters(r.db, r.chat, filters[n-1].id)
+		message = fmt.Sprintf(filterRemovedMsg, filters[n-1])
+
+	case "сброс", "reset":
+		err = rmLessonFilters(r.db, r.chat, 0)
+		message = filtersResetMsg
+
+	default:
+		f, ok := parseLessonFilter(r.args)
+		if !ok {
+			r.reply(filterUsage)
+			return
+		}
+		if len(filters) >= MAX_CHAT_FILTERS {
+			r.reply(fmt.Sprintf(tooManyFiltersMsg, MAX_CHAT_FILTERS))
+			return
+		}
+		var added bool
+		if added, err = addLessonFilter(r.db, r.chat, f); !added {
+			message = fmt.Sprintf(filterExistsMsg, f)
+		} else {
+			message = fmt.Sprintf(filterAddedMsg, f)
+		}
+	}
+
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	sendFilters(r, message+"\n\n")
+}
+
+func sendFilters(r *request, message string) {
+
+	// Функция sendFilters() отправляет список фильтров чата с номерами, по которым их можно удалить.
+	// message - результат изменения фильтров, который выводится перед списком.
+
+	filters, err := getLessonFilters(r.db, r.chat)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+
+	if len(filters) == 0 {
+		r.reply(message + noFiltersMsg + "\n\n" + filterUsage)
+		return
+	}
+
+	var list = "Фильтры занятий чата:\n"
+	for i, f := range filters {
+		list += fmt.Sprintf("%d. %s\n", i+1, f)
+	}
+
+	// Подсказка по командам нужна только при просмотре списка, после изменения достаточно самого списка.
+	if message == "" {
+		list += "\n" + filterUsage
+	}
+	r.reply(message + list)
+}
+
+func previewFilters(r *request) {
+
+	// Функция previewFilters() показывает, какие занятия групп чата скрывают фильтры, включая не подходящие
+	// ни под один показывающий фильтр, и какие остаются благодаря исключениям.
+	// Без даты проверяется текущая неделя, в воскресенье - следующая.
+
+	dates, rest, ok := requestDates(r, r.args[1:])
+	if !ok {
+		return
+	}
+	r.args = rest
+
+	if dates.from.IsZero() {
+		monday := getWeekStart(r.config.now())
+		if isSunday(r.config.now()) {
+			monday = monday.AddDate(0, 0, 7)
+		}
+		dates = dateRange{from: monday, to: monday.AddDate(0, 0, 5)}
+	}
+
+	groups, ok := requestGroups(r, filterUsage)
+	if !ok {
+		return
+	}
+
+	filters, err := getLessonFilters(r.db, r.chat)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	if len(filters) == 0 {
+		r.reply(noFiltersMsg)
+		return
+	}
+
+	for _, groupNumber := range groups {
+		lessons, err := r.schedule.LessonsBetween(groupNumber, dates.from, dates.to)
+		if err != nil {
+			r.replyError(err)
+			return
+		}
+
+		var hidden, kept []string
+		for _, l := range lessons {
+			hide, excepted := matchFilters(filters, l)
+			line := fmt.Sprintf("%s %s\n", formLessonTime(l), formLessonTitle(l))
+			switch {
+			case excepted:
+				kept = append(kept, "✅ "+line)
+			case hide:
+				hidden = append(hidden, "❌ "+line)
+			}
+		}
+
+		blocks := []string{fmt.Sprintf(filterPreviewMsg, groupNumber, dates.from.Format("02.01"), dates.to.Format("02.01.2006"),
+			len(lessons), len(lessons)-len(hidden), len(hidden))}
+		if len(hidden) == 0 {
+			blocks = append(blocks, "Фильтры не скрывают ни одного занятия.\n")
+		}
+		blocks = append(blocks, hidden...)
+		if len(kept) > 0 {
+			blocks = append(blocks, "\nОставлены исключениями:\n")
+			blocks = append(blocks, kept...)
+		}
+		for _, message := range splitMessage(blocks, MESSAGE_LIMIT) {
+			r.reply(message)
+		}
+	}
+}
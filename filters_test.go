@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestMatchFilters(t *testing.T) {
+	lecture := Lesson{Subject: "Математика", Kind: KindLecture, KindName: "Лекция", Teachers: []string{"Иванов И.И."}}
+	practice := Lesson{Subject: "Физика", Kind: KindPractice, KindName: "Практика", Teachers: []string{"Петров П.П."}}
+	selfStudy := Lesson{Subject: "Математика", Kind: KindOther, KindName: "Самостоятельная работа"}
+
+	hideSelfStudy := lessonFilter{action: FILTER_HIDE, field: FILTER_KIND, pattern: "сам"}
+	hideMath := lessonFilter{action: FILTER_HIDE, field: FILTER_SUBJECT, pattern: "математика"}
+	showMath := lessonFilter{action: FILTER_SHOW, field: FILTER_SUBJECT, pattern: "математика"}
+	showLectures := lessonFilter{action: FILTER_SHOW, field: FILTER_KIND, pattern: "лек"}
+	showPetrov := lessonFilter{action: FILTER_SHOW, field: FILTER_TEACHER, pattern: "петров"}
+
+	tests := []struct {
+		name     string
+		filters  []lessonFilter
+		lesson   Lesson
+		hidden   bool
+		excepted bool
+	}{
+		{"no filters", nil, lecture, false, false},
+		{"hide match", []lessonFilter{hideSelfStudy}, selfStudy, true, false},
+		{"hide no match", []lessonFilter{hideSelfStudy}, lecture, false, false},
+		{"show match", []lessonFilter{showMath}, lecture, false, false},
+		{"show no match", []lessonFilter{showMath}, practice, true, false},
+		{"any of several shows", []lessonFilter{showMath, showPetrov}, practice, false, false},
+		{"none of several shows", []lessonFilter{showLectures, showPetrov}, selfStudy, true, false},
+		{"show overrides hide", []lessonFilter{hideMath, showLectures}, lecture, false, true},
+		{"hide without exception", []lessonFilter{hideMath, showLectures}, selfStudy, true, false},
+		{"show and unrelated hide", []lessonFilter{hideSelfStudy, showMath}, practice, true, false},
+		{"show match and hide match", []lessonFilter{hideSelfStudy, showMath}, selfStudy, false, true},
+	}
+
+	for _, tt := range tests {
+		hidden, excepted := matchFilters(tt.filters, tt.lesson)
+		if hidden != tt.hidden || excepted != tt.excepted {
+			t.Errorf("%s: matchFilters = %v, %v; want %v, %v", tt.name, hidden, excepted, tt.hidden, tt.excepted)
+		}
+	}
+}
+
+func TestScheduleViewApplyShowFilters(t *testing.T) {
+
+	// С показывающим фильтром в расписании остаются только подходящие под него занятия, остальные считаются скрытыми.
+
+	lessons := []Lesson{
+		{Subject: "Математика", KindName: "Лекция"},
+		{Subject: "Физика", KindName: "Практика"},
+		{Subject: "Информатика", KindName: "Лабораторная работа", Subgroup: 2},
+	}
+	view := scheduleView{
+		subgroup: 1,
+		filters:  []lessonFilter{{action: FILTER_SHOW, field: FILTER_SUBJECT, pattern: "математика"}},
+	}
+
+	shown, hidden := view.apply(lessons)
+	if len(shown) != 1 || shown[0].Subject != "Математика" {
+		t.Errorf("shown = %+v", shown)
+	}
+	if hidden.subgroup != 1 || hidden.filtered != 1 {
+		t.Errorf("hidden = %+v, want 1 by subgroup and 1 by filters", hidden)
+	}
+}
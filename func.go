@@ -3,17 +3,11 @@ package main
 import (
 	"fmt"
 	"github.com/PuloV/ics-golang"
-	"github.com/essentialkaos/translit/v2"
-	"io"
-	"net/http"
 	"os"
 	"strings"
 	"time"
 )
 
-// Массив пар, типа ics.Event - событие календаря
-var lessons = make([]ics.Event, 0)
-
 func getFaculty(groupNumber string) string {
 
 	// Функция, определяющая, к какому факультету относится группа, согласно первой цифре номера группы.
@@ -50,136 +44,213 @@ func getFaculty(groupNumber string) string {
 	return ""
 }
 
-func removeFiles(fileName string) {
-
-	// Функция removeFiles() принимает название файла в качестве аргумента,
-	// и с помощью модуля os удаляет файл, если он существует.
+func getScheduleURL(baseURL string, group groupInfo) string {
 
-	if _, err := os.Stat(fileName); err == nil {
-		os.Remove(fileName)
-	}
+	// Генерация ссылки для получения расписания. Факультет и номер группы в ссылке берутся из справочника групп.
+	return baseURL + "/faculties/" + group.Faculty + "/groups/" + group.Slug + ".ics"
 }
 
-func getSchedule(groupNumber string) {
+func parseSchedule(fileName string) (lessons []Lesson, err error) {
 
-	// Функция, созданная для получения расписания с сайта в формате в .ics (iCalendar), и запись его в файл,
-	// для последующего парсинга из него.
+	// Функция parseSchedule() отвечает за разбор файла расписания и возвращает все найденные в нем занятия.
+	// Вызывается из ScheduleService, который не допускает одновременной работы нескольких парсеров.
+	// Парсер ics-golang может упасть на некорректном файле, поэтому паника превращается в ошибку разбора.
 
-	// Вызов функции для удаления предыдущего файла с расписанием выбранной группы. Костыль, но расписание в ТУСУРе непостоянное.
+	defer func() {
+		if p := recover(); p != nil {
+			lessons, err = nil, fmt.Errorf("%w: %s: %v", errScheduleFormat, fileName, p)
+		}
+	}()
 
-	removeFiles("./groups/" + groupNumber + ".ics")
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
 
-	// Создание файла *номер_группы*.ics для записи в него полученного расписания.
-	file, _ := os.Create("./groups/" + groupNumber + ".ics")
+	// Вместо календаря сайт может отдать страницу о технических работах, которую парсер принимает за пустой календарь.
+	if !strings.Contains(string(content), "BEGIN:VCALENDAR") {
+		return nil, fmt.Errorf("%w: %s is not a calendar", errScheduleFormat, fileName)
+	}
 
-	// Генерация ссылки для получения расписания. Вызов функции getFaculty() подставляет в ссылку факультет, а переменная groupNumber указывает номер учебной группы.
-	url := "https://timetable.tusur.ru/faculties/" + getFaculty(groupNumber) + "/groups/" + groupNumber + ".ics"
+	// Длинные строки в .ics переносятся на следующую строку, начинающуюся с пробела.
+	// Парсер ics-golang такие строки не склеивает, из-за чего обрезаются списки преподавателей, поэтому они склеиваются заранее.
+	unfolded := strings.NewReplacer("\r\n ", "", "\n ", "", "\r\n\t", "", "\n\t", "").Replace(string(content))
 
-	// GET-запрос на ссылку для получения расписания
-	resp, _ := http.Get(url)
+	// Создание нового парсера календаря и загрузка в него содержимого файла.
+	parser := ics.New()
+	ics.RepeatRuleApply = true
+	parser.Load(unfolded)
 
-	// Копирование полученного на запрос GET, ответа в файл
-	defer resp.Body.Close()
-	defer file.Close()
-	_, _ = io.Copy(file, resp.Body)
+	cal, err := parser.GetCalendars()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errScheduleFormat, fileName, err)
+	}
+	if len(cal) == 0 {
+		return nil, fmt.Errorf("%w: no calendars in %s", errScheduleFormat, fileName)
+	}
 
+	events := cal[0].GetEvents()
+	lessons = make([]Lesson, 0, len(events))
+	for _, e := range events {
+		lessons = append(lessons, parseLesson(e))
+	}
+	detectSubgroups(lessons)
+	return lessons, nil
 }
 
-func parseSchedule(groupNumber string, date string) {
+func formMessage(groupNumber string, day time.Time, lessons []Lesson, view scheduleView) string {
 
-	// Функция parseSchedule() отвечает за поиск пар на конкретный день в файле расписания и последующую передачу найденных
-	// пар в массив lessons, который будет хранить информацию о занятиях до конца работы с сообщением.
+	// Функция formMessage() отвечает за формирование конечного сообщения.
+	// В качестве аргументов получает номер группы, день, пары на этот день, полученные из ScheduleService, и настройки вида чата.
 
-	groupNumber = translit.EncodeToICAO(groupNumber)
+	var message = ""
 
-	// Функция getSchedule() вызывается для получения максимально актуального расписания группы.
-	getSchedule(groupNumber)
+	// Занятия других подгрупп скрываются по настройке чата.
+	lessons, hidden := view.apply(lessons)
 
-	// Массив событий календаря, в котором будут храниться занятия
-	lessons = make([]ics.Event, 0)
+	// Формирование шапки сообщения.
+	message += fmt.Sprintf("Расписание группы %s на %s (%s).\nВсего занятий - %d.\n\n", groupNumber, day.Format("02.01.2006"), getRuWeekDay(day), len(lessons))
+	message += view.hiddenNote(hidden)
 
-	// Создание нового парсера календаря, указание параметров и имени файла, подлежащего парсингу.
-	parser := ics.New()
-	ics.FilePath = "./"
-	ics.DeleteTempFiles = false
-	ics.RepeatRuleApply = true
-	inputChan := parser.GetInputChan()
-	inputChan <- "./groups/" + groupNumber + ".ics"
+	if len(lessons) == 0 {
+		message += "Занятий нет - выходные 🥳"
+	}
 
-	// parser.Wait() используется для ожидания завершения работы парсера. Результаты парсинга записываются в переменную cal, нулевой индекс.
-	parser.Wait()
-	cal, _ := parser.GetCalendars()
+	// Цикличный перебор массива пар, для формирования сообщения с расписанием.
+	for _, lesson := range lessons {
+		message += view.lesson(lesson)
+	}
+	return message
+}
 
-	// Перебор событий, полученных из календаря
-	for _, e := range cal[0].GetEvents() {
-		// Проверка, соответствует ли дата начала события, дате указанной при вызове функции.
-		if e.GetStart().Format(ics.IcsFormatWholeDay) == date {
+func formLessonCompact(lesson Lesson) string {
 
-			// При соблюдении условия, событие добавляется в массив пар.
-			lessons = append(lessons, e)
-		} else {
+	// Функция formLessonCompact() описывает пару одной строкой для краткого вида расписания.
 
-			// Иначе, просматривается следующее событие.
-			continue
-		}
+	var message = fmt.Sprintf("🕛 %s-%s %s", lesson.Start.Format("15:04"), lesson.End.Format("15:04"), formLessonTitle(lesson))
+	if len(lesson.Rooms) > 0 {
+		message += ", " + formRooms(lesson.Rooms)
 	}
-	// После перебора событий и добавления их в массив,
-	// вызывается функция sortArray() для его сортировки
-	sortArray()
+	return message + "\n"
 }
 
-func sortArray() {
+func formLesson(lesson Lesson) string {
 
-	// Сортировка пузырьком.
-	// Сортируется по времени начала события. Оказывается GO умеет сравнивать строки.
-	// "10:00" > "09:00" == true
+	// Функция formLesson() формирует описание одной пары для сообщения с расписанием.
+	// Строки о преподавателе и аудитории опускаются, если в календаре они не указаны.
 
-	for i := 0; i < len(lessons)-1; i++ {
-		for j := 0; j < len(lessons)-i-1; j++ {
-			if lessons[j].GetStart().Format("15:04") > lessons[j+1].GetStart().Format("15:04") {
-				lessons[j], lessons[j+1] = lessons[j+1], lessons[j]
-			}
+	var message = fmt.Sprintf("📖 %s\n", formLessonTitle(lesson))
+
+	if len(lesson.Teachers) > 0 {
+		message += fmt.Sprintf(" ‍👨 Преподаватель: %s\n", strings.Join(lesson.Teachers, ", "))
+	}
+	if len(lesson.Rooms) > 0 {
+		rooms := make([]string, 0, len(lesson.Rooms))
+		for _, room := range lesson.Rooms {
+			rooms = append(rooms, room.String())
 		}
+		message += fmt.Sprintf(" 🏠 Аудитория: %s\n", strings.Join(rooms, ", "))
+	}
+	if len(lesson.Notes) > 0 {
+		message += fmt.Sprintf(" ℹ %s\n", strings.Join(lesson.Notes, ", "))
 	}
+	message += fmt.Sprintf(" 🕛 Время: %s-%s\n\n", lesson.Start.Format("15:04"), lesson.End.Format("15:04"))
+	return message
 }
 
-func formMessage(groupNumber string, date string) string {
+func formWeekMessages(groupNumber string, monday time.Time, lessons []Lesson, view scheduleView) []string {
 
-	// Функция formMessage() отвечает за формирование конечного сообщения.
-	// В качестве аргументов получает дату и номер группы.
-	// По скольку массив с парами является глобальным, его не нужно передавать в функцию.
+	// Функция formWeekMessages() формирует расписание группы на неделю с понедельника по субботу.
+	// Так как расписание на неделю может не поместиться в одно сообщение ВК, результат делится на несколько сообщений.
 
-	var message = ""
-	var lessonType string // Лекция/Практика/Сам.раб/Лаб.раб - получает из поля события "Description".
-	var teacher string    // Фамилия И.О. преподавателя - получает из поля события "Description".
-	var classroom string  // номер аудитории - получает из поля события "Location" (не путать с "Geo").
+	lessons, hidden := view.apply(lessons)
+	saturday := monday.AddDate(0, 0, 5)
+	header := fmt.Sprintf("Расписание группы %s на неделю %s-%s.\nВсего занятий - %d.\n\n",
+		groupNumber, monday.Format("02.01"), saturday.Format("02.01.2006"), len(lessons)) + view.hiddenNote(hidden)
+	return formDaysMessages(header, dateRange{from: monday, to: saturday}, lessons, view)
+}
 
-	// Получение даты из аргументов.
-	var fmtDate, _ = time.Parse("20060102", date)
+func formPeriodMessages(groupNumber string, dates dateRange, lessons []Lesson, view scheduleView) []string {
 
-	// Формирование шапки сообщения.
-	message += fmt.Sprintf("Расписание группы %s на %s (%s).\nВсего занятий - %d.\n\n", groupNumber, fmtDate.Format("02.01.2006"), getRuWeekDay(fmtDate), len(lessons))
+	// Функция formPeriodMessages() формирует расписание группы на промежуток дней, названный в команде.
 
-	if len(lessons) == 0 {
-		message += "Занятий нет - выходные 🥳"
+	lessons, hidden := view.apply(lessons)
+	header := fmt.Sprintf("Расписание группы %s на %s-%s.\nВсего занятий - %d.\n\n",
+		groupNumber, dates.from.Format("02.01"), dates.to.Format("02.01.2006"), len(lessons)) + view.hiddenNote(hidden)
+	return formDaysMessages(header, dates, lessons, view)
+}
+
+func formDaysMessages(header string, dates dateRange, lessons []Lesson, view scheduleView) []string {
+
+	// Функция formDaysMessages() формирует расписание на несколько дней под общим заголовком.
+	// Пары группируются по дням, дни без занятий перечисляются одной строкой в конце. Воскресенья без занятий не упоминаются.
+
+	var blocks = []string{header}
+	var freeDays []string
+	var days = 0
+
+	for day := dates.from; !day.After(dates.to); day = day.AddDate(0, 0, 1) {
+		date := day.Format("20060102")
+
+		var block = ""
+		for _, lesson := range lessons {
+			if lesson.Start.Format("20060102") == date {
+				block += view.lesson(lesson)
+			}
+		}
+
+		if block != "" {
+			blocks = append(blocks, fmt.Sprintf("📅 %s, %s\n\n", getRuWeekDay(day), day.Format("02.01"))+block)
+			days++
+			continue
+		}
+		if isSunday(day) {
+			continue
+		}
+
+		// В расписании на неделю свободный день понятен по дню недели, в произвольном промежутке нужна и дата.
+		if dates.to.Sub(dates.from) <= 6*24*time.Hour {
+			freeDays = append(freeDays, getRuWeekDay(day))
+		} else {
+			freeDays = append(freeDays, day.Format("02.01"))
+		}
 	}
 
-	// Цикличный перебор массива пар, для формирования сообщения с расписанием.
-	for i := 0; i <= len(lessons)-1; i++ {
-
-		// !Костыль!
-		// Так как тип пары и препод хранятся в одном и том же поле, приходиться
-		// прибегать к подобному делению переменной.
-		// Аудитории разделяются слешами, из-за этого их убираю с помощью strings.ReplaceAll().
-		descriptionSplit := strings.Split(lessons[i].GetDescription(), "\\, ")
-		lessonType = descriptionSplit[0]
-		teacher = descriptionSplit[1]
-		classroom = strings.ReplaceAll(lessons[i].GetLocation(), "\\", "")
-
-		// На каждой итерации цикла, к финальному сообщению добавляется следующая пара.
-		message += fmt.Sprintf("📖 %s (%s)\n ‍👨 Преподаватель: %s\n 🏠 Аудитория: %s\n 🕛 Время: %s-%s\n\n",
-			lessons[i].GetSummary(), lessonType, teacher, classroom, //lessons[i].GetLocation(),
-			lessons[i].GetStart().Format("15:04"), lessons[i].GetEnd().Format("15:04"))
+	if days == 0 {
+		blocks = append(blocks, "Занятий нет - выходные 🥳")
+	} else if len(freeDays) > 0 {
+		blocks = append(blocks, fmt.Sprintf("Без занятий: %s 🥳", strings.Join(freeDays, ", ")))
 	}
-	return message
+	return splitMessage(blocks, MESSAGE_LIMIT)
+}
+
+func splitMessage(blocks []string, limit int) []string {
+
+	// Функция splitMessage() собирает блоки текста в сообщения длиной не более limit символов.
+	// Блоки по возможности не разрываются, а слишком длинный блок делится по строкам.
+
+	var messages []string
+	var current = ""
+
+	for _, block := range blocks {
+		if len([]rune(current))+len([]rune(block)) <= limit {
+			current += block
+			continue
+		}
+		if current != "" {
+			messages = append(messages, current)
+			current = ""
+		}
+		for _, line := range strings.SplitAfter(block, "\n") {
+			if len([]rune(current))+len([]rune(line)) > limit && current != "" {
+				messages = append(messages, current)
+				current = ""
+			}
+			current += line
+		}
+	}
+	if current != "" {
+		messages = append(messages, current)
+	}
+	return messages
 }
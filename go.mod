@@ -1,19 +1,28 @@
 module TusurScheduleBot
 
-go 1.19
+go 1.21
 
 require (
 	github.com/PuloV/ics-golang v0.0.0-20190808201353-a3394d3bcade
 	github.com/SevereCloud/vksdk/v2 v2.15.0
+	github.com/essentialkaos/translit/v2 v2.0.4
 	github.com/mattn/go-sqlite3 v1.14.15
+	github.com/prometheus/client_golang v1.19.1
+	github.com/stephenafamo/kronika v0.0.0-20220912224312-79c8aa498e30
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/channelmeter/iso8601duration v0.0.0-20150204201828-8da3af7a2a61 // indirect
-	github.com/essentialkaos/translit/v2 v2.0.4 // indirect
 	github.com/klauspost/compress v1.15.8 // indirect
-	github.com/stephenafamo/kronika v0.0.0-20220912224312-79c8aa498e30 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
 	github.com/vmihailenco/msgpack/v5 v5.3.5 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
-	golang.org/x/text v0.3.7 // indirect
-)
\ No newline at end of file
+	golang.org/x/sys v0.17.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/essentialkaos/translit/v2"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Как часто перечитывается список групп с сайта. Группы меняются раз в семестр, поэтому список хранится долго.
+const GROUP_DIRECTORY_TTL = 24 * time.Hour
+
+// Файл в папке расписаний, в котором хранится список групп между запусками бота.
+const GROUP_DIRECTORY_FILE = "groups.json"
+
+// Факультеты, которые проверяются, если со страницы списка факультетов не удалось получить ни одного.
+var knownFaculties = []string{"rtf", "rkf", "fet", "fsu", "fvs", "gf", "fb", "ef", "yuf", "fit"}
+
+// Ссылки на страницы факультетов и групп на сайте расписания:
+//
+//	<a href="/faculties/fsu">...</a>
+//	<a href="/faculties/fsu/groups/z-431p2-5">з-431п2-5</a>
+var (
+	facultyLinkRe = regexp.MustCompile(`href="/faculties/([a-z0-9_-]+)/?"`)
+	groupLinkRe   = regexp.MustCompile(`<a[^>]*href="/faculties/([a-z0-9_-]+)/groups/([^"/?#]+)"[^>]*>([^<]*)</a>`)
+)
+
+// Группа из списка групп сайта расписания.
+type groupInfo struct {
+	Name    string // Номер группы в том виде, в котором он записан на сайте, например "з-431п2-5".
+	Faculty string // Факультет из ссылки на расписание группы, например "fsu".
+	Slug    string // Номер группы в ссылке на расписание, например "z-431p2-5".
+}
+
+// Список групп с сайта расписания, сохраняемый на диск.
+type groupDirectoryData struct {
+	FetchedAt time.Time
+	Groups    []groupInfo
+}
+
+// groupDirectory - справочник существующих групп и их факультетов. Используется для проверки номера группы
+// в командах, подсказок при опечатках и построения правильной ссылки на расписание.
+type groupDirectory struct {
+	dir     string
+	baseURL string
+	client  *http.Client
+
+	mu         sync.Mutex
+	data       groupDirectoryData
+	byName     map[string]groupInfo
+	bySlug     map[string]groupInfo
+	refreshing bool // Список уже обновляется с сайта, остальные запросы пока пользуются прежним.
+}
+
+func newGroupDirectory(dir string, baseURL string) *groupDirectory {
+	d := &groupDirectory{
+		dir:     dir,
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+
+	// Сохраненный список используется сразу, не дожидаясь обращения к сайту.
+	if content, err := os.ReadFile(filepath.Join(dir, GROUP_DIRECTORY_FILE)); err == nil {
+		var data groupDirectoryData
+		if json.Unmarshal(content, &data) == nil {
+			d.set(data)
+		}
+	}
+	return d
+}
+
+func normalizeGroup(groupNumber string) string {
+
+	// Функция normalizeGroup() приводит номер группы к виду для сравнения: нижний регистр, без пробелов, "ё" заменяется на "е".
+
+	return strings.NewReplacer("ё", "е", " ", "").Replace(strings.ToLower(strings.TrimSpace(groupNumber)))
+}
+
+func (d *groupDirectory) set(data groupDirectoryData) {
+
+	// Функция set() заменяет справочник новым списком групп. Вызывается под d.mu. Карты создаются заново,
+	// а не изменяются, поэтому полученные ранее из groups() карты можно читать без блокировки.
+
+	d.data = data
+	d.byName = make(map[string]groupInfo, len(data.Groups))
+	d.bySlug = make(map[string]groupInfo, len(data.Groups))
+	for _, g := range data.Groups {
+		d.byName[normalizeGroup(g.Name)] = g
+		d.bySlug[strings.ToLower(g.Slug)] = g
+	}
+}
+
+func (d *groupDirectory) groups() map[string]groupInfo {
+
+	// Функция groups() возвращает справочник групп, не дожидаясь обращения к сайту. Обход сайта занимает
+	// несколько секунд, поэтому устаревший справочник обновляется в фоне, а до конца обновления используется прежний.
+	// Пустой результат означает, что списка еще нет вовсе, и номер группы проверить нельзя.
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.refreshing && time.Since(d.data.FetchedAt) >= GROUP_DIRECTORY_TTL {
+		d.refreshing = true
+		go d.refresh()
+	}
+	return d.byName
+}
+
+func (d *groupDirectory) refresh() {
+
+	// Функция refresh() обновляет справочник с сайта и сохраняет его на диск. Если сайт недоступен,
+	// используется последний сохраненный список.
+
+	data, err := d.fetch()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.refreshing = false
+
+	if err != nil {
+		slog.Warn("groups: using saved directory", "err", err)
+		// Следующая попытка - не раньше, чем через час, чтобы не обращаться к недоступному сайту на каждое сообщение.
+		d.data.FetchedAt = time.Now().Add(time.Hour - GROUP_DIRECTORY_TTL)
+		return
+	}
+
+	d.set(data)
+	if content, err := json.Marshal(data); err == nil {
+		if err = os.WriteFile(filepath.Join(d.dir, GROUP_DIRECTORY_FILE), content, 0o644); err != nil {
+			slog.Error("groups: save directory", "err", err)
+		}
+	}
+}
+
+func (d *groupDirectory) page(path string) (string, error) {
+	resp, err := d.client.Get(d.baseURL + path)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: unexpected status %s", path, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func (d *groupDirectory) fetch() (groupDirectoryData, error) {
+
+	// Функция fetch() собирает список групп со страниц факультетов сайта расписания.
+	// Список факультетов берется со страницы /faculties, а если ее разобрать не удалось - используется известный список.
+
+	data := groupDirectoryData{FetchedAt: time.Now()}
+
+	// Если сайт не отвечает, обходить страницы факультетов бессмысленно.
+	body, err := d.page("/faculties")
+	if err != nil {
+		return data, err
+	}
+
+	var faculties []string
+	seenFaculty := make(map[string]bool)
+	for _, m := range facultyLinkRe.FindAllStringSubmatch(body, -1) {
+		if !seenFaculty[m[1]] {
+			seenFaculty[m[1]] = true
+			faculties = append(faculties, m[1])
+		}
+	}
+	if len(faculties) == 0 {
+		faculties = knownFaculties
+	}
+
+	seen := make(map[string]bool)
+	for _, faculty := range faculties {
+		body, err := d.page("/faculties/" + faculty)
+		if err != nil {
+			slog.Warn("groups: faculty page", "faculty", faculty, "err", err)
+			continue
+		}
+		for _, m := range groupLinkRe.FindAllStringSubmatch(body, -1) {
+			slug, err := url.PathUnescape(m[2])
+			if err != nil || seen[slug] {
+				continue
+			}
+			seen[slug] = true
+
+			name := strings.TrimSpace(html.UnescapeString(m[3]))
+			if name == "" {
+				name = slug
+			}
+			data.Groups = append(data.Groups, groupInfo{Name: name, Faculty: m[1], Slug: slug})
+		}
+	}
+
+	if len(data.Groups) == 0 {
+		return data, fmt.Errorf("no groups found on %s", d.baseURL)
+	}
+	return data, nil
+}
+
+func (d *groupDirectory) resolve(groupNumber string) (groupInfo, []string, bool) {
+
+	// Функция resolve() ищет группу в справочнике по номеру, введенному пользователем. Номер можно ввести
+	// как на сайте ("з-431п2-5"), так и латиницей, как в ссылке на расписание ("z-431p2-5").
+	// Если группа не найдена, возвращаются похожие номера. Если справочника нет, номер считается верным
+	// и факультет определяется по первой цифре, как раньше.
+
+	groups := d.groups()
+	if len(groups) == 0 {
+		slug := translit.EncodeToICAO(groupNumber)
+		return groupInfo{Name: groupNumber, Faculty: getFaculty(groupNumber), Slug: slug}, nil, true
+	}
+
+	key := normalizeGroup(groupNumber)
+	if g, ok := groups[key]; ok {
+		return g, nil, true
+	}
+	d.mu.Lock()
+	g, ok := d.bySlug[key]
+	d.mu.Unlock()
+	if ok {
+		return g, nil, true
+	}
+
+	return groupInfo{}, suggestGroups(groups, key), false
+}
+
+func suggestGroups(groups map[string]groupInfo, key string) []string {
+
+	// Функция suggestGroups() подбирает до пяти номеров групп, отличающихся от введенного не более чем на два символа.
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	var candidates []candidate
+	for name, g := range groups {
+		if d := levenshtein(key, name); d <= 2 {
+			candidates = append(candidates, candidate{g.Name, d})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	var names []string
+	for i := 0; i < len(candidates) && i < 5; i++ {
+		names = append(names, candidates[i].name)
+	}
+	return names
+}
+
+func (d *groupDirectory) name(slug string) string {
+
+	// Функция name() возвращает номер группы по имени файла ее расписания в кеше, например "з-431п2-5" для "z-431p2-5".
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if g, ok := d.bySlug[strings.ToLower(slug)]; ok {
+		return g.Name
+	}
+	return slug
+}
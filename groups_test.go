@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newBlockedDirectoryServer возвращает сайт расписания, который отвечает на запрос списка факультетов
+// только после закрытия release. Закрытие started означает, что обновление справочника началось.
+func newBlockedDirectoryServer(t *testing.T) (server *httptest.Server, started chan struct{}, release chan struct{}, fetches *int32) {
+	started = make(chan struct{})
+	release = make(chan struct{})
+	fetches = new(int32)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/faculties":
+			if atomic.AddInt32(fetches, 1) == 1 {
+				close(started)
+			}
+			<-release
+			w.Write([]byte(`<a href="/faculties/fsu">ФСУ</a>`))
+		case "/faculties/fsu":
+			w.Write([]byte(`<a href="/faculties/fsu/groups/431-2">431-2</a>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+		server.Close()
+	})
+	return server, started, release, fetches
+}
+
+// waitResolved ждет, пока справочник, обновляемый в фоне, не начнет находить группу.
+func waitResolved(t *testing.T, d *groupDirectory, groupNumber string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if g, _, ok := d.resolve(groupNumber); ok && g.Faculty == "fsu" && len(d.groups()) > 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s is not resolved after the refresh", groupNumber)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGroupDirectoryRefreshInBackground(t *testing.T) {
+
+	// Пока справочник обновляется с сайта, все запросы получают прежний список, не дожидаясь обновления,
+	// и не начинают второе обновление.
+
+	server, started, release, fetches := newBlockedDirectoryServer(t)
+
+	dir := t.TempDir()
+	saved, _ := json.Marshal(groupDirectoryData{
+		FetchedAt: time.Now().Add(-2 * GROUP_DIRECTORY_TTL),
+		Groups:    []groupInfo{{Name: "431-1", Faculty: "fsu", Slug: "431-1"}},
+	})
+	if err := os.WriteFile(filepath.Join(dir, GROUP_DIRECTORY_FILE), saved, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d := newGroupDirectory(dir, server.URL)
+
+	// Обновление заблокировано на запросе к сайту, а справочник продолжает отвечать прежним списком.
+	stale := make(chan bool)
+	go func() {
+		groups := d.groups()
+		_, _, ok := d.resolve("431-1")
+		stale <- ok && len(groups) == 1 && d.name("431-1") == "431-1"
+	}()
+	select {
+	case ok := <-stale:
+		if !ok {
+			t.Error("stale directory does not resolve 431-1")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("groups() waits for the refresh")
+	}
+	<-started
+
+	close(release)
+	waitResolved(t, d, "431-2")
+	if _, _, ok := d.resolve("431-1"); ok {
+		t.Error("431-1 is still resolved after the refresh")
+	}
+	if n := atomic.LoadInt32(fetches); n != 1 {
+		t.Errorf("directory fetched %d times, want 1", n)
+	}
+
+	// Обновленный список сохраняется на диск и используется при следующем запуске.
+	if _, _, ok := newGroupDirectory(dir, "http://127.0.0.1:0").resolve("431-2"); !ok {
+		t.Error("refreshed directory is not saved")
+	}
+}
+
+func TestGroupDirectoryColdStart(t *testing.T) {
+
+	// Без сохраненного списка справочник тоже не ждет сайта: номер группы принимается как есть,
+	// а список загружается в фоне.
+
+	server, started, release, _ := newBlockedDirectoryServer(t)
+	d := newGroupDirectory(t.TempDir(), server.URL)
+
+	cold := make(chan bool)
+	go func() {
+		g, _, ok := d.resolve("431-2")
+		cold <- ok && g.Slug == "431-2"
+	}()
+	select {
+	case ok := <-cold:
+		if !ok {
+			t.Error("cold directory does not accept 431-2")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("resolve waits for the first refresh")
+	}
+	<-started
+
+	close(release)
+	waitResolved(t, d, "431-2")
+	if _, suggestions, ok := d.resolve("431-3"); ok || len(suggestions) != 1 {
+		t.Errorf("431-3 after the refresh: ok = %v, suggestions = %v", ok, suggestions)
+	}
+}
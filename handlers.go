@@ -0,0 +1,1015 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Регулярное выражение для поиска номера группы в аргументах команд.
+// Номер группы может начинаться с буквы ("з-431п2-5"), содержать буквы ("0к1-1") и окончание через тире ("431-2").
+// \w в Go соответствует только латинице, поэтому русские буквы перечисляются явно.
+var groupNumberRe = regexp.MustCompile(`(?:[a-zа-яё]{1,2}-)?\d[\da-zа-яё]{2,5}(?:-[\da-zа-яё]{1,3})?`)
+
+// Команды бота в том порядке, в котором они выводятся в справке.
+func botCommands() []*command {
+	var commands []*command
+
+	commands = append(commands,
+		&command{
+			names:   []string{"расписос", "/today", "/сегодня"},
+			usage:   "*номер_группы* *дата*",
+			help:    "расписание на сегодня или на дату: завтра, в пятницу, через 3 дня, 15 октября, 15.10, 15.10-20.10",
+			handler: handleToday,
+		},
+		&command{
+			names:   []string{"расписос на завтра", "/tomorrow", "/завтра"},
+			usage:   "*номер_группы*",
+			help:    "расписание на завтра",
+			handler: handleTomorrow,
+		},
+		&command{
+			names:   []string{"расписос на неделю", "/week", "/неделя"},
+			usage:   "*номер_группы*",
+			help:    "расписание на текущую неделю",
+			handler: handleWeek,
+		},
+		&command{
+			names:   []string{"расписос на следующую неделю", "/nextweek", "/следующаянеделя"},
+			usage:   "*номер_группы*",
+			help:    "расписание на следующую неделю",
+			handler: handleNextWeek,
+		},
+		&command{
+			names:   []string{"что сейчас", "следующая пара", "/now", "/next", "/сейчас"},
+			usage:   "*номер_группы*",
+			help:    "какая пара идет сейчас, сколько до ее конца и какая будет следующей",
+			handler: handleNow,
+		},
+		&command{
+			names:   []string{"препод", "/teacher", "/препод"},
+			usage:   "*фамилия* *дата*",
+			help:    "где и у каких групп сегодня или в указанный день занятия преподавателя",
+			handler: handleTeacher,
+		},
+		&command{
+			names:   []string{"аудитория", "/room", "/аудитория"},
+			usage:   "*корпус номер* *дата* *чч:мм*",
+			help:    "занятия и свободные окна в аудитории, или свободна ли она в указанное время",
+			handler: handleRoom,
+		},
+		&command{
+			names:   []string{"экзамены", "/exams", "/экзамены"},
+			usage:   "*номер_группы* | напоминания вкл/выкл",
+			help:    "предстоящие экзамены, зачеты и консультации группы, напоминания о них в этот чат",
+			handler: handleExams,
+		},
+		&command{
+			names:   []string{"/bind", "/привязать"},
+			usage:   "*номер_группы*",
+			help:    "автоматически получать расписание группы в этот чат, к чату можно привязать несколько групп",
+			access:  accessChatAdmin,
+			handler: handleBind,
+		},
+		&command{
+			names:   []string{"/unbind", "/отвязать"},
+			usage:   "*номер_группы*",
+			help:    "отключить автоматическое получение расписания группы, без номера - всех групп",
+			access:  accessChatAdmin,
+			handler: handleUnbind,
+		},
+		&command{
+			names:   []string{"/notify", "/уведомления"},
+			usage:   "*чч:мм* *сегодня/завтра* | удалить *чч:мм* | off | reset",
+			help:    "настроить время автоматической отправки расписания",
+			access:  accessChatAdmin,
+			handler: handleNotify,
+		},
+		&command{
+			names:   []string{"/subgroup", "/подгруппа", "подгруппа"},
+			usage:   "*1/2/все*",
+			help:    "показывать в расписании занятия только своей подгруппы",
+			handler: handleSubgroup,
+		},
+		&command{
+			names:   []string{"/filter", "/фильтр", "фильтры"},
+			usage:   "скрыть/показать предмет/вид/преподаватель *текст* | удалить *номер* | проверка *дата*",
+			help:    "скрывать в расписании чата ненужные занятия, например самостоятельную работу или факультативы",
+			handler: handleFilter,
+		},
+		&command{
+			names:   []string{"/settings", "/настройки", "настройки"},
+			help:    "меню настроек чата: время рассылки, напоминания об экзаменах, вид расписания, подгруппа",
+			handler: handleSettings,
+		},
+		&command{
+			names:   []string{"/db"},
+			help:    "список всех ассоциаций",
+			access:  accessModerator,
+			handler: handleDB,
+		},
+		&command{
+			names:   []string{"/upd"},
+			usage:   "[факультет=*факультет* | группы=*номера*] *текст*",
+			help:    "рассылка сообщения по чатам с ассоциациями после подтверждения",
+			access:  accessOwner,
+			handler: handleUpd,
+		},
+		&command{
+			names:   []string{"/admins"},
+			help:    "список администраторов бота",
+			access:  accessModerator,
+			handler: handleAdmins,
+		},
+		&command{
+			names:   []string{"/grant"},
+			usage:   "*id* *owner/moderator*",
+			help:    "назначить администратора бота",
+			access:  accessOwner,
+			handler: handleGrant,
+		},
+		&command{
+			names:   []string{"/revoke"},
+			usage:   "*id*",
+			help:    "снять администратора бота",
+			access:  accessOwner,
+			handler: handleRevoke,
+		},
+	)
+
+	// Справка формируется из описаний всех остальных команд, поэтому регистрируется последней,
+	// а ее обработчик обращается к маршрутизатору через запрос.
+	commands = append(commands, &command{
+		names:   []string{"/help", "/помощь", "/start", "помощь"},
+		help:    "получить это сообщение",
+		handler: handleHelp,
+	})
+	return commands
+}
+
+func requestGroups(r *request, usage string) ([]string, bool) {
+
+	// Функция requestGroups() возвращает номер группы, указанный в аргументах команды,
+	// а если он не указан - все группы, ассоциированные с чатом. Если нет ни того, ни другого,
+	// в чат отправляется подсказка по использованию команды.
+
+	if groupNumber := groupNumberRe.FindString(strings.Join(r.args, " ")); groupNumber != "" {
+		groupNumber, ok := resolveGroup(r, groupNumber)
+		return []string{groupNumber}, ok
+	}
+
+	groups, err := getChatGroups(r.db, r.chat)
+	if err != nil {
+		r.replyError(err)
+		return nil, false
+	}
+	if len(groups) == 0 {
+		r.reply(usage)
+		return nil, false
+	}
+	r.group = strings.Join(groups, ",")
+	return groups, true
+}
+
+func requestDates(r *request, fields []string) (dateRange, []string, bool) {
+
+	// Функция requestDates() ищет среди аргументов команды день или промежуток дней, например "завтра", "в пятницу"
+	// или "15.10-20.10", и возвращает его вместе с остальными аргументами. Если дата не указана, промежуток пустой.
+	// Если дата указана с ошибкой или промежуток слишком длинный, в чат отправляется подсказка.
+
+	dates, rest, err := parseDates(fields, r.config.now())
+	if err != nil {
+		slog.Debug("bad date", "chat", r.chat, "err", err)
+		r.reply(badDateMsg)
+		return dates, nil, false
+	}
+	if dates.to.Sub(dates.from) > MAX_PERIOD_DAYS*24*time.Hour {
+		r.reply(fmt.Sprintf(periodTooLongMsg, MAX_PERIOD_DAYS))
+		return dates, nil, false
+	}
+	return dates, rest, true
+}
+
+func resolveGroup(r *request, groupNumber string) (string, bool) {
+
+	// Функция resolveGroup() проверяет номер группы по справочнику групп и возвращает его в том виде, в котором он записан на сайте.
+	// Если такой группы нет, в чат отправляется сообщение с похожими номерами.
+
+	name, suggestions, ok := r.schedule.ResolveGroup(groupNumber)
+	if ok {
+		r.group = name
+		return name, true
+	}
+
+	if len(suggestions) > 0 {
+		r.reply(fmt.Sprintf(groupSuggestMsg, groupNumber, strings.Join(suggestions, ", ")))
+	} else {
+		r.reply(fmt.Sprintf(groupNotFoundMsg, groupNumber))
+	}
+	return "", false
+}
+
+func handleHelp(r *request) {
+
+	// Если сообщение является командой /help, то в качестве ответа будет отправлен список команд и полезной информации.
+
+	r.replyKeyboard(r.router.help(), quickKeyboard)
+}
+
+func handleBind(r *request) {
+
+	// Для команды /bind необходимо обнаружить номер группы, отправленный в сообщении,
+	// проверить наличие существующей ассоциации и, при её отсутствии, добавить ее к остальным группам чата.
+
+	// Номер группы в сообщении обнаруживается с помощью регулярного выражения.
+	groupNumber := groupNumberRe.FindString(strings.Join(r.args, " "))
+
+	groups, err := getChatGroups(r.db, r.chat)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+
+	if groupNumber == "" {
+		if len(groups) > 0 {
+			// Если группа не указана, сообщается о существующих ассоциациях.
+			r.reply(fmt.Sprintf(infoBindMsg, strings.Join(groups, ", ")))
+		} else {
+			r.reply(bindUsage)
+		}
+		return
+	}
+
+	var ok bool
+	if groupNumber, ok = resolveGroup(r, groupNumber); !ok {
+		return
+	}
+	for _, g := range groups {
+		if g == groupNumber {
+			r.reply(fmt.Sprintf(alreadyBoundMsg, groupNumber))
+			return
+		}
+	}
+	if len(groups) >= MAX_CHAT_GROUPS {
+		r.reply(fmt.Sprintf(tooManyBindsMsg, MAX_CHAT_GROUPS))
+		return
+	}
+
+	if _, err = addBinding(r.db, r.chat, groupNumber); err != nil {
+		// Если ассоциация не сохранилась, обработка сообщения заканчивается ошибкой.
+		r.replyError(err)
+		return
+	}
+
+	var message = fmt.Sprintf(successfulBindMsg, groupNumber)
+	if len(groups) > 0 {
+		message = fmt.Sprintf(successfulAddBindMsg, groupNumber, strings.Join(append(groups, groupNumber), ", "))
+	}
+
+	// После привязки под полем ввода появляются кнопки быстрых команд.
+	r.replyKeyboard(message, quickKeyboard)
+}
+
+func boundGroup(groups []string, arg string) (string, bool) {
+
+	// Функция boundGroup() находит среди групп чата указанную в команде без учета регистра и возвращает ее так, как она записана в БД.
+	// Номер не проверяется по справочнику: группа могла исчезнуть с сайта, а отвязать ее все равно нужно.
+	// Если аргумент - не номер привязанной группы, возвращается false, чтобы опечатка не отвязала все группы.
+
+	candidates := []string{strings.TrimSpace(arg), groupNumberRe.FindString(strings.ToLower(arg))}
+	for _, c := range candidates {
+		for _, g := range groups {
+			if c != "" && strings.EqualFold(g, c) {
+				return g, true
+			}
+		}
+	}
+	return "", false
+}
+
+func handleUnbind(r *request) {
+
+	// Команда /unbind *номер_группы* удаляет ассоциацию чата с указанной группой, а без номера - со всеми группами чата.
+
+	groups, err := getChatGroups(r.db, r.chat)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	if len(groups) == 0 {
+		r.reply(noBindMsg)
+		return
+	}
+
+	// Пустой номер группы удаляет все ассоциации чата, поэтому он допускается, только если аргументов нет совсем.
+	var groupNumber string
+	if len(r.args) > 0 {
+		var ok bool
+		if groupNumber, ok = boundGroup(groups, strings.Join(r.args, " ")); !ok {
+			r.reply(fmt.Sprintf(notBoundMsg, strings.Join(r.args, " "), strings.Join(groups, ", ")))
+			return
+		}
+	}
+
+	// Для удаления ассоциации вызывается функция rmBinding().
+	if _, err = rmBinding(r.db, r.chat, groupNumber); err != nil {
+		r.replyError(err)
+		return
+	}
+
+	if groupNumber != "" {
+		r.group = groupNumber
+		r.reply(fmt.Sprintf(successfulUnbindGroupMsg, groupNumber))
+	} else {
+		r.reply(successfulUnbindMsg)
+	}
+}
+
+func handleDB(r *request) {
+
+	// Команда /db отправляет все существующие ассоциации чатов с группами в качестве ответа.
+
+	message, err := getBindingsInfo(r.db)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	r.reply(message)
+}
+
+func handleUpd(r *request) {
+
+	// Команда /upd создает черновик рассылки и показывает его автору вместе с числом получателей.
+	// Рассылка отправляется только после подтверждения командой "/upd подтвердить", а до этого ее можно отменить.
+
+	if len(r.args) == 0 {
+		r.reply(updUsage)
+		return
+	}
+
+	switch r.args[0] {
+	case "подтвердить", "confirm":
+		confirmBroadcast(r)
+		return
+	case "отменить", "cancel":
+		cancelBroadcast(r)
+		return
+	}
+
+	// Первым словом можно указать получателей рассылки, по умолчанию она отправляется во все чаты.
+	target := broadcastTarget{kind: TARGET_ALL}
+	text := r.text
+	if key, value, ok := strings.Cut(r.args[0], "="); ok {
+		switch key {
+		case "факультет", "faculty":
+			target = broadcastTarget{kind: TARGET_FACULTY, value: value}
+		case "группы", "groups", "группа", "group":
+			var groups []string
+			for _, g := range strings.Split(value, ",") {
+				if g == "" {
+					continue
+				}
+				name, ok := resolveGroup(r, g)
+				if !ok {
+					return
+				}
+				groups = append(groups, name)
+			}
+			target = broadcastTarget{kind: TARGET_GROUPS, value: strings.Join(groups, ",")}
+		default:
+			r.reply(updUsage)
+			return
+		}
+		text = strings.TrimSpace(strings.TrimPrefix(text, strings.Fields(text)[0]))
+	}
+	if text == "" || target.value == "" && target.kind != TARGET_ALL {
+		r.reply(updUsage)
+		return
+	}
+
+	bc := broadcast{chat: r.chat, user: r.user, target: target, text: text}
+	chats, err := r.broadcasts.recipients(target)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	if bc.id, err = createBroadcast(r.db, bc); err != nil {
+		r.replyError(err)
+		return
+	}
+	r.reply(fmt.Sprintf(broadcastDraftMsg, bc.id, target, len(chats), text, bc.id, bc.id))
+}
+
+func broadcastArg(r *request) (int64, bool) {
+
+	// Функция broadcastArg() возвращает номер рассылки из команды подтверждения или отмены.
+	// Если номер не указан, используется последний черновик автора команды.
+
+	if len(r.args) > 1 {
+		id, err := strconv.ParseInt(strings.TrimPrefix(r.args[1], "#"), 10, 64)
+		if err != nil {
+			r.reply(updUsage)
+			return 0, false
+		}
+		return id, true
+	}
+
+	id, ok, err := getLastDraft(r.db, r.user)
+	if err != nil {
+		r.replyError(err)
+		return 0, false
+	}
+	if !ok {
+		r.reply(broadcastNoDraftMsg)
+	}
+	return id, ok
+}
+
+func confirmBroadcast(r *request) {
+	id, ok := broadcastArg(r)
+	if !ok {
+		return
+	}
+
+	ok, err := setBroadcastStatus(r.db, id, BROADCAST_DRAFT, BROADCAST_QUEUED)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	if !ok {
+		replyBroadcastState(r, id, broadcastNotDraftMsg)
+		return
+	}
+
+	// Если очередь заполнена, рассылка снова становится черновиком, и ее можно подтвердить позже.
+	if !r.broadcasts.enqueue(id) {
+		if _, err = setBroadcastStatus(r.db, id, BROADCAST_QUEUED, BROADCAST_DRAFT); err != nil {
+			r.replyError(err)
+			return
+		}
+		r.reply(fmt.Sprintf(broadcastQueueFullMsg, id, id))
+		return
+	}
+	r.reply(fmt.Sprintf(broadcastQueuedMsg, id))
+}
+
+func cancelBroadcast(r *request) {
+	id, ok := broadcastArg(r)
+	if !ok {
+		return
+	}
+
+	// Отменить можно и черновик, и подтвержденную рассылку, которую еще не начали отправлять.
+	for _, status := range []string{BROADCAST_DRAFT, BROADCAST_QUEUED} {
+		ok, err := setBroadcastStatus(r.db, id, status, BROADCAST_CANCELLED)
+		if err != nil {
+			r.replyError(err)
+			return
+		}
+		if ok {
+			r.reply(fmt.Sprintf(broadcastCancelledMsg, id))
+			return
+		}
+	}
+	replyBroadcastState(r, id, broadcastNotCancellableMsg)
+}
+
+func replyBroadcastState(r *request, id int64, message string) {
+
+	// Функция replyBroadcastState() объясняет, почему с рассылкой ничего не сделано: ее нет или она уже в другом состоянии.
+
+	_, found, err := getBroadcast(r.db, id)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	if !found {
+		message = broadcastNotFoundMsg
+	}
+	r.reply(fmt.Sprintf(message, id))
+}
+
+func handleAdmins(r *request) {
+
+	// Команда /admins отправляет список администраторов бота: владельцев из настроек и назначенных командой /grant.
+
+	admins, err := getAdmins(r.db)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+
+	var message = "Администраторы бота:\n"
+	if r.config.AdminID != 0 {
+		message += fmt.Sprintf("👑 %s - owner (из настроек)\n", chatID{Platform: PLATFORM_VK, ID: int64(r.config.AdminID)})
+	}
+	if r.config.TelegramAdminID != 0 {
+		message += fmt.Sprintf("👑 %s - owner (из настроек)\n", chatID{Platform: PLATFORM_TELEGRAM, ID: r.config.TelegramAdminID})
+	}
+	for _, a := range admins {
+		icon := "🛡"
+		if a.role == ROLE_OWNER {
+			icon = "👑"
+		}
+		message += fmt.Sprintf("%s %s - %s (назначил %s)\n", icon, a.user, a.role, a.grantedBy)
+	}
+	r.reply(message)
+}
+
+func handleGrant(r *request) {
+
+	// Команда /grant назначает пользователю роль: owner - все команды, moderator - просмотр ассоциаций
+	// и управление настройками любого чата. Пользователь указывается как "vk:123", "tg:123" или упоминанием.
+
+	fields := userArgs(r.text)
+	if len(fields) != 2 {
+		r.reply(grantUsage)
+		return
+	}
+
+	user, ok := parseUserArg(r, fields[0])
+	role := strings.ToLower(fields[1])
+	if !ok || (role != ROLE_OWNER && role != ROLE_MODERATOR) {
+		r.reply(grantUsage)
+		return
+	}
+
+	if err := setRole(r.db, user, role, r.user); err != nil {
+		r.replyError(err)
+		return
+	}
+	r.reply(fmt.Sprintf(grantMsg, user, role))
+}
+
+func handleRevoke(r *request) {
+
+	// Команда /revoke снимает с пользователя роль. Владельца из настроек бота снять нельзя.
+
+	fields := userArgs(r.text)
+	if len(fields) != 1 {
+		r.reply(revokeUsage)
+		return
+	}
+
+	user, ok := parseUserArg(r, fields[0])
+	if !ok {
+		r.reply(revokeUsage)
+		return
+	}
+	if r.config.isOwner(user) {
+		r.reply(revokeConfigOwnerMsg)
+		return
+	}
+
+	removed, err := rmRole(r.db, user)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	if !removed {
+		r.reply(fmt.Sprintf(revokeNoRoleMsg, user))
+		return
+	}
+	r.reply(fmt.Sprintf(revokeMsg, user))
+}
+
+func handleToday(r *request) {
+
+	// "Расписос" отправляет расписание группы на сегодня или на день, названный в сообщении:
+	// "завтра", "в пятницу", "через 3 дня", "15 октября", "15.10". Для промежутка дней ("15.10-20.10",
+	// "на следующей неделе") расписание отправляется по дням. Если сегодня воскресенье, отправляется расписание на понедельник.
+
+	var message = ""
+
+	dates, rest, ok := requestDates(r, r.args)
+	if !ok {
+		return
+	}
+	r.args = rest
+
+	if dates.from.IsZero() {
+		today := dates.day(r.config.now())
+		if isSunday(today) {
+			today = today.AddDate(0, 0, 1)
+			message += exTodayIsSunday
+		}
+		dates = dateRange{from: today, to: today}
+	}
+
+	groups, ok := requestGroups(r, raspisosUsage)
+	if !ok {
+		return
+	}
+
+	// Если к чату привязано несколько групп, расписание каждой отправляется отдельным сообщением.
+	for _, groupNumber := range groups {
+		if dates.single() {
+			sendDay(r, groupNumber, dates.from, message)
+			message = ""
+		} else {
+			sendPeriod(r, groupNumber, dates)
+		}
+	}
+}
+
+func handleTomorrow(r *request) {
+
+	// "Расписос на завтра" подразумевает все то же самое, что и "расписос", но на дату завтрашнего дня.
+
+	var message = ""
+	var date = r.config.now().AddDate(0, 0, 1)
+
+	if isSunday(date) {
+		date = date.AddDate(0, 0, 1)
+		message += exTommorowIsSunday
+	}
+
+	groups, ok := requestGroups(r, raspisosTommorowUsage)
+	if !ok {
+		return
+	}
+	for _, groupNumber := range groups {
+		sendDay(r, groupNumber, date, message)
+		message = ""
+	}
+}
+
+func sendDay(r *request, groupNumber string, date time.Time, message string) {
+
+	// Функция sendDay() отправляет в чат расписание группы на день, дописывая его к уже подготовленному сообщению.
+
+	lessons, err := r.schedule.LessonsFor(groupNumber, date)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	r.reply(message + formMessage(groupNumber, date, lessons, getScheduleView(r.db, r.chat)))
+}
+
+func handleNow(r *request) {
+
+	// "Что сейчас" сообщает, какая пара идет у группы по текущему времени бота и какая будет следующей.
+
+	groups, ok := requestGroups(r, nowUsage)
+	if !ok {
+		return
+	}
+
+	now := r.config.now()
+	for _, groupNumber := range groups {
+		lessons, err := r.schedule.LessonsBetween(groupNumber, now, now.AddDate(0, 0, NEXT_LESSON_HORIZON_DAYS))
+		if err != nil {
+			r.replyError(err)
+			return
+		}
+		lessons, _ = getScheduleView(r.db, r.chat).apply(lessons)
+		r.reply(formNowMessage(groupNumber, now, lessons))
+	}
+}
+
+func handleWeek(r *request) {
+
+	// "Расписос на неделю" отправляет расписание с понедельника по субботу текущей недели.
+	// В воскресенье текущая неделя уже закончилась, поэтому берется следующая.
+
+	monday := getWeekStart(r.config.now())
+	if isSunday(r.config.now()) {
+		monday = monday.AddDate(0, 0, 7)
+	}
+	sendWeek(r, monday)
+}
+
+func handleNextWeek(r *request) {
+
+	// "Расписос на следующую неделю" отправляет расписание с понедельника по субботу следующей недели.
+
+	sendWeek(r, getWeekStart(r.config.now()).AddDate(0, 0, 7))
+}
+
+func sendPeriod(r *request, groupNumber string, dates dateRange) {
+	lessons, err := r.schedule.LessonsBetween(groupNumber, dates.from, dates.to)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	for _, message := range formPeriodMessages(groupNumber, dates, lessons, getScheduleView(r.db, r.chat)) {
+		r.reply(message)
+	}
+}
+
+func sendWeek(r *request, monday time.Time) {
+	groups, ok := requestGroups(r, raspisosWeekUsage)
+	if !ok {
+		return
+	}
+
+	for _, groupNumber := range groups {
+		lessons, err := r.schedule.LessonsBetween(groupNumber, monday, monday.AddDate(0, 0, 5))
+		if err != nil {
+			r.replyError(err)
+			return
+		}
+
+		// Расписание на неделю может занимать несколько сообщений, они отправляются по очереди.
+		for _, message := range formWeekMessages(groupNumber, monday, lessons, getScheduleView(r.db, r.chat)) {
+			r.reply(message)
+		}
+	}
+}
+
+func handleTeacher(r *request) {
+
+	// "Препод" отправляет расписание преподавателя на сегодня или на день, названный в сообщении ("завтра", "в пятницу", "15.10").
+	// Преподаватель ищется по фамилии среди всех известных расписаний групп, с учетом опечаток.
+	// Расписание берется с сайта, а если там преподаватель не найден или сайт недоступен - собирается из расписаний групп в кеше.
+
+	dates, fields, ok := requestDates(r, strings.Fields(r.text))
+	if !ok {
+		return
+	}
+	date := dates.day(r.config.now())
+
+	query := strings.Join(fields, " ")
+	if query == "" {
+		r.reply(teacherUsage)
+		return
+	}
+
+	groups := r.schedule.CachedGroups()
+	teachers := findTeachers(groups, query)
+	if len(teachers) > 1 {
+		r.reply(fmt.Sprintf(teacherAmbiguousMsg, strings.Join(teachers, "\n")))
+		return
+	}
+
+	teacher := query
+	if len(teachers) == 1 {
+		teacher = teachers[0]
+	}
+
+	lessons, err := r.schedule.TeacherLessons(teacher, date)
+	if err == nil {
+		r.reply(formTeacherMessage(teacher, date, lessons))
+		return
+	}
+
+	// Без преподавателя в кеше сообщить, что он не найден, можно только по ответу поиска на сайте,
+	// а если сайт недоступен - пользователь узнает об этом, как и в остальных командах.
+	if len(teachers) == 0 {
+		if errors.Is(err, errLecturerNotFound) {
+			r.reply(fmt.Sprintf(teacherNotFoundMsg, query))
+		} else {
+			r.replyError(err)
+		}
+		return
+	}
+	slog.Warn("teacher: using cached group schedules", "teacher", teacher, "err", err)
+	r.reply(formTeacherMessage(teacher, date, collectLessons(groups, date, func(l Lesson) bool {
+		return hasTeacher(l, teacher)
+	})) + teacherCachedNote)
+}
+
+func handleExams(r *request) {
+
+	// "Экзамены" отправляет предстоящие экзамены, зачеты и консультации группы с числом дней до каждого из них.
+	// "Экзамены напоминания вкл/выкл" включает в чате напоминания накануне вечером и утром в день экзамена.
+
+	if len(r.args) > 0 && (r.args[0] == "напоминания" || r.args[0] == "reminders") {
+		setExamReminders(r)
+		return
+	}
+
+	groups, ok := requestGroups(r, examsUsage)
+	if !ok {
+		return
+	}
+
+	// Экзамены других подгрупп и скрытые фильтрами чата не показываются, как и в остальном расписании.
+	view := getScheduleView(r.db, r.chat)
+	now := r.config.now()
+	for _, groupNumber := range groups {
+		lessons, err := r.schedule.AllLessons(groupNumber)
+		if err != nil {
+			r.replyError(err)
+			return
+		}
+		lessons, _ = view.apply(lessons)
+		for _, message := range formExamsMessages(groupNumber, now, upcomingExams(lessons, now)) {
+			r.reply(message)
+		}
+	}
+}
+
+func setExamReminders(r *request) {
+
+	// Напоминания - настройка чата, поэтому, как и /notify, менять ее могут только администраторы беседы.
+
+	if !r.require(accessChatAdmin) {
+		return
+	}
+
+	groups, err := getChatGroups(r.db, r.chat)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	if len(groups) == 0 {
+		r.reply(examRemindersNoBindMsg)
+		return
+	}
+	groupNumber := strings.Join(groups, ", ")
+	r.group = strings.Join(groups, ",")
+
+	value, err := getChatSetting(r.db, r.chat, SETTING_EXAM_REMINDERS, "off")
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	if len(r.args) > 1 {
+		switch r.args[1] {
+		case "вкл", "on":
+			value = "on"
+		case "выкл", "off":
+			value = "off"
+		default:
+			r.reply(examsUsage)
+			return
+		}
+		if err = setChatSetting(r.db, r.chat, SETTING_EXAM_REMINDERS, value); err != nil {
+			r.replyError(err)
+			return
+		}
+	}
+
+	if value == "on" {
+		r.reply(fmt.Sprintf(examRemindersOnMsg, groupNumber, r.config.EveningTime, r.config.MorningTime))
+	} else {
+		r.reply(examRemindersOffMsg)
+	}
+}
+
+func handleRoom(r *request) {
+
+	// "Аудитория" отправляет занятия в аудитории на сегодня или на названный день и свободные окна между ними.
+	// Если указано время ("аудитория рк 418 в 14:00"), отвечает, свободна ли аудитория в это время.
+	// Сведения собираются из расписаний групп, известных боту.
+
+	var at string
+	var fields []string
+
+	dates, args, ok := requestDates(r, strings.Fields(r.text))
+	if !ok {
+		return
+	}
+	date := dates.day(r.config.now())
+	for _, field := range args {
+		if strings.Contains(field, ":") && normalizeClock(field) != "" {
+			at = normalizeClock(field)
+		} else if strings.ToLower(field) != "в" {
+			fields = append(fields, field)
+		}
+	}
+
+	if len(fields) == 0 {
+		r.reply(roomUsage)
+		return
+	}
+
+	groups := r.schedule.CachedGroups()
+	room, similar := findRooms(groups, strings.Join(fields, " "))
+	if room == "" {
+		if len(similar) > 0 {
+			r.reply(fmt.Sprintf(roomSimilarMsg, strings.Join(fields, " "), strings.Join(similar, ", ")))
+		} else {
+			r.reply(fmt.Sprintf(roomNotFoundMsg, strings.Join(fields, " ")))
+		}
+		return
+	}
+
+	normalized := normalizeRoom(room)
+	lessons := collectLessons(groups, date, func(l Lesson) bool {
+		return hasRoom(l, normalized)
+	})
+
+	if at == "" {
+		r.reply(formRoomMessage(room, date, lessons))
+		return
+	}
+	t, _ := time.Parse("15:04", at)
+	r.reply(formRoomAt(room, time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, date.Location()), lessons))
+}
+
+func handleNotify(r *request) {
+
+	// Команда /notify настраивает время, в которое чат получает расписание:
+	// 	/notify - показать текущие настройки;
+	// 	/notify 07:15 сегодня - каждый день в 07:15 присылать расписание на сегодня (завтра - на завтра);
+	// 	/notify удалить 07:15 - убрать время рассылки;
+	// 	/notify off - отключить рассылку, /notify reset - вернуть стандартное время.
+
+	var err error
+
+	switch {
+	case len(r.args) == 0:
+		r.reply(formNotifySlots(r))
+		return
+
+	case r.args[0] == "off" || r.args[0] == "выкл":
+		err = resetNotifySlots(r.db, r.chat, true)
+
+	case r.args[0] == "reset" || r.args[0] == "сброс":
+		err = resetNotifySlots(r.db, r.chat, false)
+
+	case (r.args[0] == "удалить" || r.args[0] == "remove") && len(r.args) == 2:
+		var removed bool
+		removed, err = rmNotifySlot(r.db, r.chat, normalizeClock(r.args[1]))
+		if err == nil && !removed {
+			r.reply(fmt.Sprintf(notifyNoSlotMsg, r.args[1]))
+			return
+		}
+
+	default:
+		slot, ok := parseNotifySlot(r.args)
+		if !ok {
+			r.reply(notifyUsage)
+			return
+		}
+		err = setNotifySlot(r.db, r.chat, slot)
+	}
+
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	r.reply(formNotifySlots(r))
+}
+
+func normalizeClock(value string) string {
+
+	// Функция normalizeClock() приводит время вида "7:15" или "07.15" к виду "07:15". При ошибке возвращается пустая строка.
+
+	t, err := time.Parse("15:04", strings.ReplaceAll(value, ".", ":"))
+	if err != nil {
+		return ""
+	}
+	return t.Format("15:04")
+}
+
+func parseNotifySlot(args []string) (notifySlot, bool) {
+
+	// Функция parseNotifySlot() разбирает аргументы "чч:мм [сегодня|завтра]".
+	// Если день не указан, до полудня выбирается расписание на сегодня, после - на завтра.
+
+	slot := notifySlot{Time: normalizeClock(args[0])}
+	if slot.Time == "" || len(args) > 2 {
+		return slot, false
+	}
+
+	if len(args) == 1 {
+		slot.Day = NOTIFY_TODAY
+		if slot.Time >= "12:00" {
+			slot.Day = NOTIFY_TOMORROW
+		}
+		return slot, true
+	}
+
+	switch args[1] {
+	case "today", "сегодня":
+		slot.Day = NOTIFY_TODAY
+	case "tomorrow", "завтра":
+		slot.Day = NOTIFY_TOMORROW
+	default:
+		return slot, false
+	}
+	return slot, true
+}
+
+func formNotifySlots(r *request) string {
+
+	// Функция formNotifySlots() формирует сообщение с текущими настройками рассылки чата.
+
+	slots, err := getNotifySlots(r.db, r.chat)
+	if err != nil {
+		return unhandledErrMsg
+	}
+
+	if len(slots) == 0 {
+		slots = []notifySlot{{r.config.MorningTime, NOTIFY_TODAY}, {r.config.EveningTime, NOTIFY_TOMORROW}}
+	} else if slots[0].Day == NOTIFY_OFF {
+		return notifyOffMsg
+	}
+
+	var message = "Расписание присылается в этот чат:\n"
+	for _, slot := range slots {
+		day := "на сегодня"
+		if slot.Day == NOTIFY_TOMORROW {
+			day = "на завтра"
+		}
+		message += fmt.Sprintf("🕛 %s - %s\n", slot.Time, day)
+	}
+	return message + notifyHintMsg
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"github.com/PuloV/ics-golang"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// Вид занятия, определяемый по первой части поля DESCRIPTION.
+type LessonKind int
+
+const (
+	KindOther LessonKind = iota
+	KindLecture
+	KindPractice
+	KindLab
+	KindSelfStudy
+	KindExam
+	KindCredit
+	KindConsultation
+)
+
+func (k LessonKind) String() string {
+	switch k {
+	case KindLecture:
+		return "Лекция"
+	case KindPractice:
+		return "Практика"
+	case KindLab:
+		return "Лаб"
+	case KindSelfStudy:
+		return "Сам.раб"
+	case KindExam:
+		return "Экзамен"
+	case KindCredit:
+		return "Зачёт"
+	case KindConsultation:
+		return "Консультация"
+	}
+	return ""
+}
+
+// Аудитория занятия. LOCATION в календаре ТУСУРа имеет вид "рк 418", где "рк" - корпус, а "418" - номер.
+type Room struct {
+	Building string
+	Number   string
+}
+
+func (r Room) String() string {
+	if r.Building == "" {
+		return r.Number
+	}
+	return r.Building + " " + r.Number
+}
+
+// Lesson - занятие группы, полученное из события календаря.
+type Lesson struct {
+	Subject  string
+	Kind     LessonKind
+	KindName string // Вид занятия в том виде, в котором он записан в календаре.
+	Teachers []string
+	Notes    []string // Прочие пометки из DESCRIPTION, например "Реализуется в ЭИОС".
+	Rooms    []Room
+	Start    time.Time
+	End      time.Time
+	Subgroup int  // Номер подгруппы из названия или пометок занятия, 0 - подгруппа не указана.
+	Parallel bool // В то же время идет такое же занятие в другой аудитории или у другого преподавателя, а подгруппа не указана.
+}
+
+// Часовой пояс, используемый, если в событии он не указан или неизвестен.
+var scheduleLocation, _ = time.LoadLocation("Asia/Novosibirsk")
+
+var (
+	teacherRe  = regexp.MustCompile(`^[А-ЯЁ][а-яё]+(-[А-ЯЁ][а-яё]+)?\s+[А-ЯЁ]\.\s?([А-ЯЁ]\.)?$`)
+	subgroupRe = regexp.MustCompile(`(?i)(?:(\d)\s*-?\s*(?:я\s*)?(?:подгр\S*|п/г))|(?:(?:подгр\S*|п/г)\s*№?\s*(\d))`)
+)
+
+func (l Lesson) KindLabel() string {
+
+	// Название вида занятия для вывода. Для неизвестных видов выводится то, что записано в календаре.
+
+	if l.Kind == KindOther {
+		return l.KindName
+	}
+	return l.Kind.String()
+}
+
+func (l Lesson) SubgroupLabel() string {
+
+	// Пометка для вывода у занятий подгрупп, в которых подгруппа не указана. Номер подгруппы, указанный
+	// в названии или пометках занятия, уже виден в сообщении, а угадывать его по порядку событий нельзя.
+
+	if l.Subgroup == 0 && l.Parallel {
+		return "параллельное занятие"
+	}
+	return ""
+}
+
+func parseLessonKind(name string) LessonKind {
+	name = strings.ToLower(name)
+
+	switch {
+	case strings.HasPrefix(name, "лекц"):
+		return KindLecture
+	case strings.HasPrefix(name, "практ"):
+		return KindPractice
+	case strings.HasPrefix(name, "лаб"):
+		return KindLab
+	case strings.HasPrefix(name, "сам"):
+		return KindSelfStudy
+	case strings.Contains(name, "экзамен"):
+		return KindExam
+	case strings.Contains(name, "зач"):
+		return KindCredit
+	case strings.HasPrefix(name, "консул"):
+		return KindConsultation
+	}
+	return KindOther
+}
+
+func splitEscaped(value string, sep byte) []string {
+
+	// Функция splitEscaped() делит значение поля календаря по разделителю, экранированному обратным слешем ("\,"),
+	// и снимает экранирование с каждой части. Пустые части отбрасываются.
+
+	var parts []string
+	var part strings.Builder
+
+	flush := func() {
+		if p := strings.TrimSpace(part.String()); p != "" {
+			parts = append(parts, p)
+		}
+		part.Reset()
+	}
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			part.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case sep:
+			flush()
+		case 'n', 'N':
+			part.WriteByte('\n')
+		default:
+			part.WriteByte(value[i])
+		}
+	}
+	flush()
+	return parts
+}
+
+func unescapeText(value string) string {
+
+	// Снятие экранирования с текстового поля календаря без деления его на части.
+
+	return strings.Join(splitEscaped(value, 0), "")
+}
+
+func parseRoom(value string) Room {
+	fields := strings.Fields(value)
+	if len(fields) == 2 {
+		return Room{Building: fields[0], Number: fields[1]}
+	}
+	return Room{Number: strings.Join(fields, " ")}
+}
+
+func parseSubgroup(values ...string) int {
+	for _, v := range values {
+		m := subgroupRe.FindStringSubmatch(v)
+		if m == nil {
+			continue
+		}
+		n := m[1]
+		if n == "" {
+			n = m[2]
+		}
+		subgroup, _ := strconv.Atoi(n)
+		return subgroup
+	}
+	return 0
+}
+
+func eventTime(t time.Time, tzid string) time.Time {
+
+	// Парсер ics-golang игнорирует TZID и возвращает время события в UTC,
+	// поэтому показания часов переносятся в часовой пояс, указанный в событии.
+
+	loc := scheduleLocation
+	if tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+}
+
+func parseLesson(e ics.Event) Lesson {
+
+	// Функция parseLesson() собирает занятие из события календаря.
+	// DESCRIPTION имеет вид "Вид занятия\, Фамилия И.О.\, ...", но может не содержать преподавателя
+	// или содержать пометки вроде "Реализуется в ЭИОС", поэтому каждая часть проверяется отдельно.
+
+	lesson := Lesson{
+		Start: eventTime(e.GetStart(), e.GetStartTZID()),
+		End:   eventTime(e.GetEnd(), e.GetEndTZID()),
+	}
+	lesson.Subject = unescapeText(e.GetSummary())
+
+	description := splitEscaped(e.GetDescription(), ',')
+	if len(description) > 0 {
+		lesson.KindName = description[0]
+		lesson.Kind = parseLessonKind(description[0])
+		description = description[1:]
+	}
+	for _, part := range description {
+		if teacherRe.MatchString(part) {
+			lesson.Teachers = append(lesson.Teachers, part)
+		} else {
+			lesson.Notes = append(lesson.Notes, part)
+		}
+	}
+
+	for _, room := range splitEscaped(e.GetLocation(), ',') {
+		lesson.Rooms = append(lesson.Rooms, parseRoom(room))
+	}
+
+	lesson.Subgroup = parseSubgroup(append([]string{lesson.Subject}, lesson.Notes...)...)
+	return lesson
+}
+
+func detectSubgroups(lessons []Lesson) {
+
+	// Функция detectSubgroups() размечает занятия подгрупп. Номер подгруппы берется только из названия и пометок
+	// занятия ("Информатика (2 подгр.)"). В календаре ТУСУРа у занятий подгрупп пометка бывает не всегда: это несколько
+	// событий с одним предметом и видом занятия в одно и то же время, но в разных аудиториях или у разных преподавателей.
+	// Порядок таких событий в календаре ничего не говорит о подгруппе, поэтому непомеченные занятия только отмечаются
+	// как параллельные и показываются всем подгруппам. Номера, назначенные по порядку событий в снимках расписания,
+	// сохраненных раньше, при этом пересчитываются.
+
+	type slot struct {
+		subject string
+		kind    string
+		start   int64
+		end     int64
+	}
+
+	slots := make(map[slot][]int)
+	for i, l := range lessons {
+		lessons[i].Subgroup = parseSubgroup(append([]string{l.Subject}, l.Notes...)...)
+		lessons[i].Parallel = false
+
+		subject := strings.Trim(subgroupRe.ReplaceAllString(l.Subject, ""), " ()")
+		key := slot{subject, l.KindName, l.Start.Unix(), l.End.Unix()}
+		slots[key] = append(slots[key], i)
+	}
+
+	for _, parallel := range slots {
+		if len(parallel) < 2 {
+			continue
+		}
+
+		places := make(map[string]bool)
+		for _, i := range parallel {
+			places[fmt.Sprint(lessons[i].Subject, lessons[i].Rooms, lessons[i].Teachers)] = true
+		}
+
+		// Одинаковые события - повтор в календаре, а не подгруппы.
+		if len(places) < 2 {
+			continue
+		}
+
+		for _, i := range parallel {
+			lessons[i].Parallel = lessons[i].Subgroup == 0
+		}
+	}
+}
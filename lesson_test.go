@@ -0,0 +1,247 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func parseTestCalendar(t *testing.T, content string) []Lesson {
+	t.Helper()
+
+	fileName := filepath.Join(t.TempDir(), "test.ics")
+	if err := os.WriteFile(fileName, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lessons, err := parseSchedule(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lessons
+}
+
+func TestParseLesson(t *testing.T) {
+	tests := []struct {
+		name        string
+		summary     string
+		description string
+		location    string
+		want        Lesson
+	}{
+		{
+			name:        "description without comma",
+			summary:     "Физическая культура",
+			description: "Практика",
+			location:    "рк 101",
+			want: Lesson{Subject: "Физическая культура", Kind: KindPractice, KindName: "Практика",
+				Rooms: []Room{{"рк", "101"}}},
+		},
+		{
+			name:        "several teachers",
+			summary:     "Иностранный язык",
+			description: "Практика\\, Войцеховская Н.Ю.\\, Зиско О.Ю.\\, Филичёнок В.",
+			location:    "рк 307",
+			want: Lesson{Subject: "Иностранный язык", Kind: KindPractice, KindName: "Практика",
+				Teachers: []string{"Войцеховская Н.Ю.", "Зиско О.Ю.", "Филичёнок В."}, Rooms: []Room{{"рк", "307"}}},
+		},
+		{
+			name:        "escaped commas and notes",
+			summary:     "История\\, философия",
+			description: "Лекция\\, Реализуется в ЭИОС\\, Иванов И.И.",
+			location:    "",
+			want: Lesson{Subject: "История, философия", Kind: KindLecture, KindName: "Лекция",
+				Teachers: []string{"Иванов И.И."}, Notes: []string{"Реализуется в ЭИОС"}},
+		},
+		{
+			name:        "several rooms",
+			summary:     "Физика",
+			description: "Лабораторная работа\\, Петров П.П.",
+			location:    "рк 307\\, фэт 415\\, Дистанционно",
+			want: Lesson{Subject: "Физика", Kind: KindLab, KindName: "Лабораторная работа",
+				Teachers: []string{"Петров П.П."}, Rooms: []Room{{"рк", "307"}, {"фэт", "415"}, {"", "Дистанционно"}}},
+		},
+		{
+			name:        "unknown kind",
+			summary:     "Курсовой проект",
+			description: "Курсовое проектирование\\, Сидоров С.С.",
+			location:    "гк 224",
+			want: Lesson{Subject: "Курсовой проект", Kind: KindOther, KindName: "Курсовое проектирование",
+				Teachers: []string{"Сидоров С.С."}, Rooms: []Room{{"гк", "224"}}},
+		},
+		{
+			name:        "subgroup marker",
+			summary:     "Информатика (2 подгр.)",
+			description: "Лаб\\, Смирнов А.А.",
+			location:    "рк 418",
+			want: Lesson{Subject: "Информатика (2 подгр.)", Kind: KindLab, KindName: "Лаб",
+				Teachers: []string{"Смирнов А.А."}, Rooms: []Room{{"рк", "418"}}, Subgroup: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lessons := parseTestCalendar(t, testCalendarHeader+
+				testEvent("20221017T084500", "20221017T102000", tt.summary, tt.description, tt.location)+testCalendarFooter)
+			if len(lessons) != 1 {
+				t.Fatalf("got %d lessons, want 1", len(lessons))
+			}
+
+			got := lessons[0]
+			tt.want.Start = time.Date(2022, 10, 17, 8, 45, 0, 0, scheduleLocation)
+			tt.want.End = time.Date(2022, 10, 17, 10, 20, 0, 0, scheduleLocation)
+			if !got.Start.Equal(tt.want.Start) || !got.End.Equal(tt.want.End) {
+				t.Errorf("time = %v-%v, want %v-%v", got.Start, got.End, tt.want.Start, tt.want.End)
+			}
+			got.Start, got.End = tt.want.Start, tt.want.End
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFoldedLines(t *testing.T) {
+
+	// Длинная строка DESCRIPTION переносится на следующую строку, начинающуюся с пробела, и не должна обрезаться.
+
+	content := testCalendarHeader +
+		"BEGIN:VEVENT\r\n" +
+		"DTEND;TZID=Asia/Novosibirsk;VALUE=DATE-TIME:20221017T102000\r\n" +
+		"DTSTART;TZID=Asia/Novosibirsk;VALUE=DATE-TIME:20221017T084500\r\n" +
+		"DESCRIPTION:Практика\\, Войцеховская Н.Ю.\\, Зиско О.Ю.\\, По\r\n" +
+		" пова С.Н.\r\n" +
+		"SUMMARY:Иностранный язык\r\n" +
+		"LOCATION:рк 307\r\n" +
+		"END:VEVENT\r\n" + testCalendarFooter
+
+	lessons := parseTestCalendar(t, content)
+	if len(lessons) != 1 {
+		t.Fatalf("got %d lessons, want 1", len(lessons))
+	}
+	want := []string{"Войцеховская Н.Ю.", "Зиско О.Ю.", "Попова С.Н."}
+	if !reflect.DeepEqual(lessons[0].Teachers, want) {
+		t.Errorf("teachers = %q, want %q", lessons[0].Teachers, want)
+	}
+}
+
+func TestParseSample162(t *testing.T) {
+
+	// Настоящее расписание группы 162 с сайта ТУСУРа: все события должны разбираться без паники и без пустых предметов.
+
+	lessons, err := parseSchedule("groups/162.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lessons) == 0 {
+		t.Fatal("no lessons in groups/162.ics")
+	}
+
+	var foundLanguage, foundNoRoom bool
+	for _, l := range lessons {
+		if l.Subject == "" {
+			t.Errorf("empty subject in lesson at %v", l.Start)
+		}
+		if l.Start.Location().String() != "Asia/Novosibirsk" || !l.End.After(l.Start) {
+			t.Errorf("bad time %v-%v for %q", l.Start, l.End, l.Subject)
+		}
+		if len(l.Rooms) == 0 {
+			foundNoRoom = true
+		}
+
+		// 06.09.2022 в 08:50 - иностранный язык: перенесенная строка с пятью преподавателями и пять аудиторий.
+		if l.Subject == "Иностранный язык" && l.Start.Equal(time.Date(2022, 9, 6, 8, 50, 0, 0, scheduleLocation)) {
+			foundLanguage = true
+			if len(l.Teachers) != 5 || l.Teachers[3] != "Попова С.Н." {
+				t.Errorf("teachers = %q", l.Teachers)
+			}
+			if len(l.Rooms) != 5 || l.Rooms[0] != (Room{"рк", "307"}) {
+				t.Errorf("rooms = %v", l.Rooms)
+			}
+			if l.Kind != KindPractice {
+				t.Errorf("kind = %v", l.Kind)
+			}
+		}
+	}
+	if !foundLanguage {
+		t.Error("lesson on 06.09.2022 08:50 not found")
+	}
+	if !foundNoRoom {
+		t.Error("lessons without LOCATION are expected in the sample")
+	}
+}
+
+func TestDetectSubgroups(t *testing.T) {
+
+	// Номер подгруппы берется только из текста события, а одновременные занятия без пометки отмечаются
+	// как параллельные независимо от порядка событий в календаре.
+
+	first := testEvent("20221017T084500", "20221017T102000", "Информатика", "Лабораторная работа\\, Смирнов А.А.", "рк 418")
+	second := testEvent("20221017T084500", "20221017T102000", "Информатика", "Лабораторная работа\\, Кузнецов К.К.", "рк 419")
+	marked := testEvent("20221017T084500", "20221017T102000", "Информатика (2 подгр.)", "Лабораторная работа\\, Кузнецов К.К.", "рк 419")
+	lecture := testEvent("20221017T104000", "20221017T121500", "Информатика", "Лекция\\, Смирнов А.А.", "рк 101")
+
+	type result struct {
+		teacher  string
+		subgroup int
+		parallel bool
+	}
+	tests := []struct {
+		name   string
+		events string
+		want   []result
+	}{
+		{"parallel without markers", first + second, []result{{"Смирнов А.А.", 0, true}, {"Кузнецов К.К.", 0, true}}},
+		{"parallel in other order", second + first, []result{{"Смирнов А.А.", 0, true}, {"Кузнецов К.К.", 0, true}}},
+		{"one of two marked", first + marked, []result{{"Смирнов А.А.", 0, true}, {"Кузнецов К.К.", 2, false}}},
+		{"repeated event", first + first, []result{{"Смирнов А.А.", 0, false}, {"Смирнов А.А.", 0, false}}},
+		{"different time", first + lecture, []result{{"Смирнов А.А.", 0, false}, {"Смирнов А.А.", 0, false}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lessons := parseTestCalendar(t, testCalendarHeader+tt.events+testCalendarFooter)
+			if len(lessons) != len(tt.want) {
+				t.Fatalf("got %d lessons, want %d", len(lessons), len(tt.want))
+			}
+			for _, want := range tt.want {
+				found := false
+				for _, l := range lessons {
+					if l.Teachers[0] == want.teacher && l.Subgroup == want.subgroup && l.Parallel == want.parallel {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("no lesson %+v in %+v", want, lessons)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectSubgroupsResetsSnapshot(t *testing.T) {
+
+	// В снимках, сохраненных раньше, непомеченные занятия подгрупп пронумерованы по порядку событий.
+	// После разметки они не отличаются от свежего расписания, и ложных изменений не находится.
+
+	events := testCalendarHeader +
+		testEvent("20221017T084500", "20221017T102000", "Информатика", "Лабораторная работа\\, Смирнов А.А.", "рк 418") +
+		testEvent("20221017T084500", "20221017T102000", "Информатика", "Лабораторная работа\\, Кузнецов К.К.", "рк 419") +
+		testCalendarFooter
+	lessons := parseTestCalendar(t, events)
+
+	old := append([]Lesson(nil), lessons...)
+	old[0].Subgroup, old[1].Subgroup = 2, 1
+	old[0].Parallel, old[1].Parallel = false, false
+	detectSubgroups(old)
+
+	if changes := diffLessons(old, lessons); len(changes) != 0 {
+		t.Errorf("got changes %+v, want none", changes)
+	}
+	for _, l := range old {
+		if l.Subgroup != 0 || !l.Parallel || l.SubgroupLabel() != "параллельное занятие" {
+			t.Errorf("lesson not reset: %+v", l)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+func newLogger(cfg *Config) *slog.Logger {
+
+	// Функция newLogger() создает логгер бота с уровнем и форматом из настроек.
+	// Записи структурированы: чат, команда, группа и прочие подробности передаются отдельными полями,
+	// чтобы по логу можно было найти все события одного чата.
+
+	var level slog.Level
+	level.UnmarshalText([]byte(cfg.LogLevel))
+
+	opts := &slog.HandlerOptions{Level: level}
+	if cfg.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, opts))
+}
+
+func fatal(msg string, err error) {
+
+	// Функция fatal() записывает в лог ошибку, после которой бот не может продолжать работу, и завершает его.
+
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
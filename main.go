@@ -3,253 +3,106 @@ package main
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"github.com/SevereCloud/vksdk/v2/api"
-	"github.com/SevereCloud/vksdk/v2/api/params"
-	"github.com/SevereCloud/vksdk/v2/events"
-	"github.com/SevereCloud/vksdk/v2/longpoll-bot"
 	_ "github.com/mattn/go-sqlite3"
-	"log"
-	"regexp"
-	"strings"
-	"time"
+	"log/slog"
 )
 
 func main() {
 
-	// Подключение к БД sqlite3
-	db, err := sql.Open("sqlite3", "./sqlite.db")
-
-	// Подключение к API VK с помощью токена, и получение группы, от которой был получен токен.
-	vk := api.NewVK(TOKEN)
-	group, _ := vk.GroupsGetByID(nil)
-
-	go cronSending(db, vk)
-
-	// Создание нового lonpoll'а для обработки событий
-	lp, _ := longpoll.NewLongPoll(vk, group[0].ID)
-
-	// Функция, обрабатывающая новое событие получения нового сообщения.
-	lp.MessageNew(func(_ context.Context, obj events.MessageNewObject) {
-
-		var message = ""
-		b := params.NewMessagesSendBuilder()
-		b.RandomID(0)
-		b.PeerID(obj.Message.PeerID)
-
-		// Перевод сообщения в нижний регистр для последующего поиска в нем.
-		text := strings.ToLower(obj.Message.Text)
-
-		// Блок сообщений-команд.
-
-		if strings.Contains(text, "/help") {
-
-			// Если сообщение содержит текст "/help", то в качестве ответа будет отправлена переменная helpMsg (messages.go),
-			// содержащее список команд и полезной информации.
-
-			// Сборка сообщения-ответа.
-			b.Message(helpMsg)
-			vk.MessagesSend(b.Params)
-			return
-		}
-
-		if strings.Contains(text, "/bind") {
-
-			// Если сообщение содержит текст "/bind", необходимо обнаружить номер группы, отправленный в сообщении,
-			// проверить наличие существующей ассоциации и, при её отсутствии, создать с ней новую.
-
-			var groupNumber string
-
-			// Номер группы в сообщении обнаруживается с помощью регулярного выражения.
-			re := regexp.MustCompile(`(\d\w\d)(\-\w{0,2})?`)
-			groupNumber = re.FindString(text)
-
-			bindFlag, bindGroup := getBinding(db, obj.Message.PeerID)
-
-			if groupNumber != "" {
-				if !bindFlag {
-					if setBinding(db, obj.Message.PeerID, groupNumber) {
-						// Если результат положительный, обработка сообщения заканчивается и задается финальное сообщение.
-						message = fmt.Sprintf(successfulBindMsg, groupNumber)
-					} else {
-						// Если результат отрицательный, обработка сообщения заканчивается ошибкой и задается финальное сообщение о ней.
-						message = unhandledErrMsg
-					}
-				} else {
-					rmBinding(db, obj.Message.PeerID)
-					setBinding(db, obj.Message.PeerID, groupNumber)
-					message = fmt.Sprintf(successfulRebindMsg, bindGroup, groupNumber)
-				}
-			} else {
-				// Если синтаксис команды неправильный, обработка сообщения заканчивается ошибкой и задается финальное сообщение о ней.
-				message = fmt.Sprintf(infoBindMsg, bindGroup)
-			}
-
-			// Сборка сообщения-ответа.
-			b.Message(message)
-			vk.MessagesSend(b.Params)
-			return
-		}
-
-		if strings.Contains(text, "/unbind") {
-
-			// Если сообщение содержит текст "/unbind", необходимо обнаружить номер группы, отправленный в сообщении,
-			// проверить наличие существующей с ней ассоциации и, при её наличии, удалить ее.
-
-			isBound, _ := getBinding(db, obj.Message.PeerID)
-
-			if !isBound {
-				message = noBindMsg
-			}
-			// Для удаления ассоциации вызывается функция rmBinding().
-			if rmBinding(db, obj.Message.PeerID) {
-
-				// В случае положительного ответа от функции, обработка сообщения заканчивается и задается финальное сообщение.
-				message = "Ассоциация удалена."
-			} else {
-				// Иначе, обработка сообщения заканчивается ошибкой и задается финальное сообщение о ней.
-				message = unhandledErrMsg
-			}
-
-			// Сборка сообщения-ответа.
-			b.Message(message)
-			vk.MessagesSend(b.Params)
-			return
-		}
-
-		// Блок служебных команд
+	// Загрузка настроек из файла и переменных окружения. Без корректных настроек бот не запускается.
+	cfg, err := loadConfig()
+	if err != nil {
+		fatal("config", err)
+	}
+	slog.SetDefault(newLogger(cfg))
 
-		if strings.Contains(text, "/db") {
+	// Подключение к БД sqlite3
+	db, err := sql.Open("sqlite3", cfg.DBPath)
+	if err != nil {
+		fatal("open db", err)
+	}
 
-			// Если сообщение содержит текст "/db", необходимо отправить все существующие ассоциации чатов с группами в качестве ответа.
+	// Обновление схемы БД до последней версии.
+	if err = migrate(db); err != nil {
+		fatal("migrate db", err)
+	}
 
-			// Так как функция служебная, необходимо проверять, от кого приходит сообщение.
-			// Если сообщение пришло не от меня, то в качестве ответа отправляется сообщение о нехватке доступа.
-			if obj.Message.PeerID != 366661090 {
-				b.Message(noAccess)
-			} else {
-				// Иначе отправляется информация об ассоциациях.
-				b.Message(getBindingsInfo(db))
-			}
-			vk.MessagesSend(b.Params)
-			return
+	// Подключение мессенджеров, для которых в настройках указан токен.
+	ms := make(messengers)
+	if cfg.VKToken != "" {
+		vk, err := newVKMessenger(cfg.VKToken)
+		if err != nil {
+			fatal("connect to vk", err)
 		}
+		ms[vk.platform()] = vk
+	}
+	if cfg.TelegramToken != "" {
+		tg := newTelegramMessenger(cfg.TelegramAPIURL, cfg.TelegramToken)
+		ms[tg.platform()] = tg
+	}
 
-		if strings.Contains(text, "/upd ") {
-
-			// Если сообщение содержит текст "/udp", то в качестве ответа будет отправлена переменная helpMsg (messages.go),
-			// содержащее список команд и полезной информации.
-
-			if obj.Message.PeerID != 366661090 {
-				b.Message(noAccess)
-			} else {
-				msg := strings.ReplaceAll(obj.Message.Text, "/upd", "")
-				b.PeerID(366661090)
-				b.Message(sendUpdMessage(db, vk, msg))
-			}
-			vk.MessagesSend(b.Params)
-			// Сборка сообщения-ответа.
-			return
-		}
+	// Сервис расписаний, читающий файлы групп через кеш и проверяющий номера групп по справочнику с сайта.
+	// Справочник начинает обновляться в фоне сразу при запуске, чтобы к первым командам он уже был готов.
+	directory := newGroupDirectory(cfg.GroupsDir, cfg.TimetableURL)
+	directory.groups()
+	schedule := NewScheduleService(
+		newScheduleCache(cfg.GroupsDir, cfg.TimetableURL, cfg.CacheTTL),
+		directory,
+	)
+
+	// Метрики публикуются, только если в настройках указан адрес для них.
+	if cfg.MetricsAddr != "" {
+		go serveMetrics(cfg.MetricsAddr)
+	}
 
-		// Блок расписания.
+	// Рассылки /upd отправляются в фоне, чтобы бот продолжал отвечать на команды.
+	broadcasts := newBroadcaster(db, ms, schedule)
+	go broadcasts.run()
 
-		if strings.Contains(text, "расписос на завтра") {
+	go cronSending(cfg, db, ms, schedule)
+	go watchScheduleChanges(cfg, db, ms, schedule)
 
-			// "Расписос на завтра" подразумевает все то же самое, что и "расписос", но на дату завтрашнего дня.
+	// Маршрутизатор, определяющий по тексту сообщения, какой команде оно адресовано.
+	commands := newRouter(botCommands()...)
 
-			var date string
-			var tomorrow = time.Now().AddDate(0, 0, 1)
-			var groupNumber string
-			var bindFlag bool
+	// Все мессенджеры получают сообщения одновременно. Остановка любого из них завершает работу бота.
+	ctx := context.Background()
+	errs := make(chan error, len(ms))
 
-			re := regexp.MustCompile(`(\d\w\d)(\-\w{0,2})?`)
-			groupNumber = re.FindString(text)
+	for _, m := range ms {
+		go func(m messenger) {
 
-			re = regexp.MustCompile(`\d\d\.\d\d`)
-			date = re.FindString(text)
+			// Функция, обрабатывающая новое входящее сообщение.
+			errs <- m.run(ctx, func(msg incomingMessage) {
 
-			if date != "" {
-				date = date + ".2022"
-			} else {
-				if isSunday(tomorrow) {
-					date = tomorrow.AddDate(0, 0, 1).Format("20060102")
-					message += exTommorowIsSunday
-				} else {
-					// Иначе, используется сегодняшняя дата
-					date = tomorrow.Format("20060102")
-				}
-			}
-
-			if groupNumber == "" {
-				bindFlag, groupNumber = getBinding(db, obj.Message.PeerID)
-				if !bindFlag {
-					message = raspisosTommorowUsage
-					b.Message(message)
-					vk.MessagesSend(b.Params)
-					return
+				// Нажатие на кнопку подтверждается сразу, чтобы клиент не показывал загрузку, пока бот готовит ответ.
+				if msg.event != nil {
+					if err := m.answer(msg.chat.ID, msg.user, msg.event); err != nil {
+						slog.Warn("answer button event", "chat", msg.chat, "err", err)
+					}
 				}
-			}
-			parseSchedule(groupNumber, date)
-			message = formMessage(groupNumber, date)
-
-			// Собираем сообщение-ответ
-			b.Message(message)
-			vk.MessagesSend(b.Params)
-			return
-		}
 
-		if strings.Contains(text, "расписос") {
-
-			var date string
-			var today = time.Now()
-			var groupNumber string
-			var bindFlag bool
-
-			re := regexp.MustCompile(`(\d\w\d)(\-\w{0,2})?`)
-			groupNumber = re.FindString(text)
-
-			re = regexp.MustCompile(`\d\d\.\d\d`)
-			date = re.FindString(text)
-
-			if date != "" {
-				date = date + ".2022"
-				splitDate := strings.Split(date, ".")
-				date = fmt.Sprintf("%s%s%s", splitDate[2], splitDate[1], splitDate[0])
-			} else {
-				if isSunday(today) {
-					date = today.AddDate(0, 0, 1).Format("20060102")
-					message += exTodayIsSunday
-				} else {
-					// Иначе, используется сегодняшняя дата
-					date = today.Format("20060102")
-				}
-			}
-
-			if groupNumber == "" {
-				bindFlag, groupNumber = getBinding(db, obj.Message.PeerID)
-				if !bindFlag {
-					message = raspisosUsage
-					b.Message(message)
-					vk.MessagesSend(b.Params)
-					return
+				r := &request{
+					chat:       msg.chat,
+					user:       chatID{Platform: msg.chat.Platform, ID: msg.user},
+					event:      msg.event,
+					config:     cfg,
+					db:         db,
+					messenger:  m,
+					messengers: ms,
+					schedule:   schedule,
+					broadcasts: broadcasts,
 				}
-			}
-
-			parseSchedule(groupNumber, date)
-			message = formMessage(groupNumber, date)
 
-			// Собираем сообщение-ответ
-			b.Message(message)
-			vk.MessagesSend(b.Params)
-			return
-		}
-	})
+				// Сообщения, не являющиеся командами, бот игнорирует.
+				commands.dispatch(r, msg.text)
+			})
+		}(m)
+	}
 
-	// Запуск lp-хендлера
-	err = lp.Run()
+	err = <-errs
 	if err != nil {
-		log.Fatal(err)
+		fatal("messenger stopped", err)
 	}
 
 }
@@ -1,28 +1,147 @@
 package main
 
 // /help
-var helpMsg = "ℹ Получить это сообщение - /help\n\n" +
-	"Подробную справку читай по ссылке - vk.com/@tusurschedulebot-spravochka"
+var helpFooterMsg = "Подробную справку читай по ссылке - vk.com/@tusurschedulebot-spravochka"
 
 // /bind
 
 var successfulBindMsg = "Теперь ваша группа автоматически будет получать расписание группы %s.\n" +
 	"Для получения подробной информации введите /help."
-var successfulRebindMsg = "Ассоциация с группой %s удалена.\n" +
-	"Теперь ваша группа автоматически будет получать расписание группы %s.\n" +
+var successfulAddBindMsg = "Группа %s добавлена. Теперь чат получает расписание групп: %s.\n" +
+	"Отвязать одну из групп: /unbind *номер_группы*."
+var alreadyBoundMsg = "Чат уже получает расписание группы %s."
+var tooManyBindsMsg = "К чату можно привязать не больше %d групп. Сначала отвяжите одну из них: /unbind *номер_группы*."
+var infoBindMsg = "Чат получает расписание групп: %s.\n" +
+	"Добавить группу: /bind *номер_группы*, отвязать: /unbind *номер_группы*.\n" +
 	"Для получения подробной информации введите /help."
-var infoBindMsg = "Существует ассоциация чата с группой %s.\n" +
-	"Если хотите изменить группу, используйте команду: /bind *номер_группы*.\n" +
+var bindUsage = "Использование: /bind *номер_группы*.\n" +
 	"Для получения подробной информации введите /help."
+var groupNotFoundMsg = "Группа %s не найдена на сайте расписания. Проверьте номер группы."
+var groupSuggestMsg = "Группа %s не найдена на сайте расписания. Возможно, вы имели в виду: %s."
 var noBindMsg = "Нечего удалять - ассоциации не существует."
+var notBoundMsg = "Чат не получает расписание группы %s. Привязанные группы: %s."
+var successfulUnbindMsg = "Ассоциация удалена."
+var successfulUnbindGroupMsg = "Ассоциация с группой %s удалена."
+
+// /notify
+
+var notifyUsage = "Использование: /notify *чч:мм* *сегодня/завтра*, /notify удалить *чч:мм*, /notify off или /notify reset.\n" +
+	"Для получения подробной информации введите /help."
+var notifyNoSlotMsg = "Рассылка в %s не настроена."
+var notifyOffMsg = "Автоматическая отправка расписания в этот чат отключена.\n" +
+	"Чтобы включить ее, укажите время: /notify *чч:мм* *сегодня/завтра* или /notify reset."
+var notifyHintMsg = "\nВремя указано по Томску. Изменить его можно командой /notify."
+
+// препод
+
+var teacherUsage = "Использование: препод *фамилия* *дата*, например: препод Иванов в пятницу.\n" +
+	"Для получения подробной информации введите /help."
+var teacherAmbiguousMsg = "Под запрос подходят несколько преподавателей, уточните инициалы:\n%s"
+var teacherNotFoundMsg = "Преподаватель \"%s\" не найден."
+var teacherCachedNote = "\nРасписание собрано из расписаний групп, известных боту, и может быть неполным."
+
+// аудитория
+
+var roomUsage = "Использование: аудитория *корпус номер* *дата* *чч:мм*, например: аудитория рк 418 в 14:00.\n" +
+	"Для получения подробной информации введите /help."
+var roomNotFoundMsg = "Аудитория \"%s\" не найдена в расписаниях, известных боту."
+var roomSimilarMsg = "Аудитория \"%s\" не найдена. Возможно, вы имели в виду: %s."
+var roomCachedNote = "\nСведения собраны из расписаний групп, известных боту, и могут быть неполными."
+
+// /grant, /revoke
+
+var grantUsage = "Использование: /grant *id* *owner/moderator*, где id - vk:123, tg:123 или упоминание пользователя."
+var grantMsg = "Пользователь %s теперь %s."
+var revokeUsage = "Использование: /revoke *id*, где id - vk:123, tg:123 или упоминание пользователя."
+var revokeMsg = "Пользователь %s больше не является администратором бота."
+var revokeNoRoleMsg = "Пользователь %s не является администратором бота."
+var revokeConfigOwnerMsg = "Владелец бота указан в настройках, снять его командой нельзя."
+
+// что сейчас
+
+var nowUsage = "Использование: что сейчас *номер_группы*.\n" +
+	"Для получения подробной информации введите /help."
+
+// /subgroup
+
+var subgroupUsage = "Использование: /subgroup *1/2/все* - показывать в расписании занятия только своей подгруппы или всех подгрупп."
+var subgroupInfoMsg = "Подгруппа в расписании этого чата: %s.\nИзменить: /subgroup *1/2/все*."
+var subgroupSetMsg = "Теперь в расписании показываются занятия всей группы и подгруппы %d. Показать все подгруппы: /subgroup все."
+var subgroupAllMsg = "Теперь в расписании показываются занятия всех подгрупп."
+var subgroupHiddenMsg = "Показана подгруппа %d, скрыто занятий других подгрупп - %d. Показать все: /subgroup все.\n"
+
+// /filter
+
+var filterUsage = "Использование:\n" +
+	"/filter скрыть предмет/вид/преподаватель *текст* - скрывать занятия, в которых встречается текст, например: /filter скрыть вид сам.раб\n" +
+	"/filter показать предмет/вид/преподаватель *текст* - показывать только такие занятия, даже если их скрывает другой фильтр\n" +
+	"/filter удалить *номер*, /filter сброс - удалить один или все фильтры\n" +
+	"/filter проверка *дата* - какие занятия скрывают фильтры, по умолчанию - на этой неделе"
+var noFiltersMsg = "У чата нет фильтров занятий - в расписании показываются все занятия."
+var filterAddedMsg = "Фильтр добавлен: %s."
+var filterExistsMsg = "Такой фильтр уже есть: %s."
+var filterRemovedMsg = "Фильтр удален: %s."
+var filtersResetMsg = "Все фильтры удалены."
+var tooManyFiltersMsg = "У чата может быть не больше %d фильтров. Сначала удалите ненужные: /filter удалить *номер*."
+var filterHiddenMsg = "Скрыто фильтрами чата - %d. Список фильтров: /filter.\n"
+var filterPreviewMsg = "Проверка фильтров, группа %s, %s-%s.\nВсего занятий - %d, показано - %d, скрыто - %d.\n\n"
+
+// /settings
+
+var settingsUsage = "Использование: /settings - меню настроек чата с кнопками."
+var settingsHintMsg = "Нажмите на кнопку, чтобы изменить настройку. Менять настройки беседы могут ее администраторы."
+var groupsMenuHintMsg = "Нажмите на группу, чтобы отвязать ее. Добавить группу: /bind *номер_группы*."
+var notifyMenuHintMsg = "Нажмите на время, чтобы включить или выключить рассылку в это время. " +
+	"Другое время можно указать командой /notify *чч:мм* *сегодня/завтра*. Время указано по Томску."
+
+// экзамены
+
+var examsUsage = "Использование: экзамены *номер_группы* - предстоящие экзамены, зачеты и консультации;\n" +
+	"экзамены напоминания вкл/выкл - напоминания об экзаменах группы этого чата."
+var noExamsMsg = "У группы %s нет предстоящих экзаменов, зачетов и консультаций. Возможно, расписание сессии еще не опубликовано."
+var examReminderMsg = "⏰ Напоминание: %[2]s у группы %[1]s:\n\n"
+var examRemindersOnMsg = "Напоминания об экзаменах группы %s включены: накануне в %s и в день экзамена в %s."
+var examRemindersOffMsg = "Напоминания об экзаменах выключены. Включить: экзамены напоминания вкл."
+var examRemindersNoBindMsg = "Чтобы получать напоминания об экзаменах, сначала привяжите группу к чату командой /bind."
+
+// /upd
+
+var updUsage = "Использование:\n" +
+	"/upd *текст* - рассылка по всем чатам;\n" +
+	"/upd факультет=*факультет* *текст* - по чатам групп факультета, например факультет=fsu;\n" +
+	"/upd группы=*номер,номер* *текст* - по чатам перечисленных групп.\n" +
+	"Бот сначала покажет черновик, а отправит его только после /upd подтвердить."
+var broadcastDraftMsg = "Черновик рассылки #%d\nПолучатели: %s, чатов: %d\n\n%s\n\n" +
+	"Отправить: /upd подтвердить %d\nОтменить: /upd отменить %d"
+var broadcastNoDraftMsg = "Нет черновика рассылки, ожидающего подтверждения."
+var broadcastNotFoundMsg = "Рассылка #%d не найдена."
+var broadcastNotDraftMsg = "Рассылка #%d уже подтверждена или отменена."
+var broadcastQueuedMsg = "Рассылка #%d поставлена в очередь. Когда она закончится, сюда придет отчет."
+var broadcastQueueFullMsg = "Очередь рассылок заполнена, рассылка #%d не поставлена в очередь. Подтвердите ее позже: /upd подтвердить %d"
+var broadcastNotStartedMsg = "Рассылку #%d не удалось начать: не получен список получателей. Создайте ее заново."
+var broadcastCancelledMsg = "Рассылка #%d отменена."
+var broadcastNotCancellableMsg = "Рассылку #%d уже нельзя отменить."
+var broadcastReportMsg = "Рассылка #%d завершена.\nДоставлено: %d\nНе доставлено: %d\nБот заблокирован или удален из чата: %d"
+var broadcastFailedMsg = "\n\nНе доставлено в: %s"
+var broadcastBlockedMsg = "\n\nБот заблокирован в: %s"
 
 // расписос
 
-var raspisosTommorowUsage = "Использование: расписос *номер_группы* *дд.мм*.\n" +
+var raspisosTommorowUsage = "Использование: расписос на завтра *номер_группы*.\n" +
+	"Для получения подробной информации введите /help."
+var raspisosUsage = "Использование: расписос *номер_группы* *дата*, например: расписос 431-2 в пятницу.\n" +
 	"Для получения подробной информации введите /help."
-var raspisosUsage = "Использование: расписос *номер_группы*.\n" +
+var raspisosWeekUsage = "Использование: расписос на неделю *номер_группы*.\n" +
 	"Для получения подробной информации введите /help."
+var badDateMsg = "Не удалось понять дату. Примеры: завтра, послезавтра, в пятницу, в следующую среду, через 3 дня, " +
+	"15 октября, 15.10, 15.10.26, 15.10-20.10, на следующей неделе."
+var periodTooLongMsg = "Расписание можно получить не больше чем на %d дней за раз."
 var exTodayIsSunday = "Сегодня воскресенье, но вот расписание на понедельник: \n"
 var exTommorowIsSunday = "Завтра воскресенье, но вот расписание на понедельник: \n"
 var noAccess = "У вас нет прав на использование этой команды."
+var noChatAdminAccess = "Эта команда доступна только администраторам беседы."
+var chatAdminsUnavailableMsg = "Не удалось проверить, являетесь ли вы администратором беседы. Для этого бот должен быть администратором беседы."
+var groupUnavailableMsg = "Расписание этой группы не найдено на сайте. Проверьте номер группы."
+var siteUnavailableMsg = "Сайт расписания сейчас недоступен. Попробуйте позже."
+var scheduleFormatMsg = "Не удалось прочитать расписание с сайта. Попробуйте позже."
 var unhandledErrMsg = "Что-то пошло не так. Уведомите об этом автора бота.\nДля получения подробной информации введите /help."
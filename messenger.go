@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+// Платформы, через которые работает бот.
+const PLATFORM_VK = "vk"
+const PLATFORM_TELEGRAM = "tg"
+
+// Чат на одной из платформ. ID чатов разных платформ могут совпадать, поэтому чат определяется парой "платформа - ID",
+// которая хранится в БД в столбцах platform и peer_id.
+type chatID struct {
+	Platform string
+	ID       int64
+}
+
+func (c chatID) String() string {
+	return c.Platform + ":" + strconv.FormatInt(c.ID, 10)
+}
+
+// В логе чат записывается одной строкой "платформа:ID", как и в сообщениях бота.
+func (c chatID) LogValue() slog.Value {
+	return slog.StringValue(c.String())
+}
+
+// Кнопка клавиатуры. В чате на кнопке написан label, а бот при нажатии получает command, как если бы ее отправили сообщением.
+type button struct {
+	label   string
+	command string
+}
+
+// Клавиатура с кнопками. Обычная клавиатура показывается под полем ввода сообщения и остается там,
+// а встроенная (inline) прикрепляется к сообщению бота, и нажатие на ее кнопки не оставляет сообщений в чате.
+type keyboard struct {
+	rows   [][]button
+	inline bool
+}
+
+// Нажатие на кнопку встроенной клавиатуры.
+type buttonEvent struct {
+	id        string // ID события, которым мессенджер подтверждает нажатие.
+	messageID int64  // Сообщение бота с клавиатурой: conversation_message_id в ВК, message_id в Telegram.
+}
+
+// Входящее сообщение, полученное ботом на любой из платформ.
+type incomingMessage struct {
+	chat  chatID
+	user  int64 // ID автора сообщения. В личных сообщениях совпадает с ID чата.
+	text  string
+	event *buttonEvent // Для нажатия на кнопку встроенной клавиатуры text - команда кнопки.
+}
+
+// Мессенджер, через который бот получает и отправляет сообщения.
+type messenger interface {
+	platform() string
+
+	// Отправка сообщения в чат. Клавиатура может быть nil.
+	send(peerID int64, message string, kb *keyboard) error
+
+	// Замена текста и клавиатуры сообщения бота, например меню настроек после нажатия на его кнопку.
+	edit(peerID int64, messageID int64, message string, kb *keyboard) error
+
+	// Подтверждение нажатия на кнопку встроенной клавиатуры. Без него клиент долго показывает загрузку на кнопке.
+	answer(peerID int64, userID int64, event *buttonEvent) error
+
+	// Получение входящих сообщений до отмены контекста или ошибки.
+	run(ctx context.Context, handler func(incomingMessage)) error
+
+	// Проверка, является ли пользователь администратором беседы. В личных сообщениях пользователь управляет своим чатом сам.
+	isChatAdmin(peerID int64, userID int64) (bool, error)
+}
+
+// Набор мессенджеров бота по названию платформы.
+type messengers map[string]messenger
+
+func (ms messengers) send(chat chatID, message string) error {
+
+	// Функция send() отправляет сообщение в чат через мессенджер его платформы.
+
+	m, ok := ms[chat.Platform]
+	if !ok {
+		return fmt.Errorf("%w %q", errMessengerMissing, chat.Platform)
+	}
+	return m.send(chat.ID, message, nil)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"net/http"
+)
+
+// Метрики бота в формате Prometheus. Отдаются по адресу /metrics, если в настройках указан metrics_addr.
+var (
+	messagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsb_messages_total",
+		Help: "Incoming messages by recognized command, \"none\" for messages that are not commands.",
+	}, []string{"command"})
+
+	scheduleFetchesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsb_schedule_fetches_total",
+		Help: "Schedule cache lookups: hit - fresh copy, miss - downloaded or revalidated, error - download failed.",
+	}, []string{"result"})
+
+	sendFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsb_send_failures_total",
+		Help: "Messages that could not be sent, by platform.",
+	}, []string{"platform"})
+
+	cronDeliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsb_cron_deliveries_total",
+		Help: "Scheduled deliveries by time slot (HH:MM) and result.",
+	}, []string{"slot", "result"})
+
+	downloadSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tsb_schedule_download_seconds",
+		Help:    "Time spent downloading a calendar from the timetable site.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	parseSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tsb_schedule_parse_seconds",
+		Help:    "Time spent parsing a calendar file.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+func serveMetrics(addr string) {
+
+	// Функция serveMetrics() запускает HTTP-сервер с метриками. Ошибка сервера не останавливает бота, а только записывается в лог.
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	slog.Info("metrics: listening", "addr", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		slog.Error("metrics: server stopped", "addr", addr, "err", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Миграции схемы БД. Файлы называются "*номер*_*описание*.sql" и применяются по возрастанию номера.
+//
+//go:embed assets/migrations/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+func loadMigrations() ([]migration, error) {
+
+	// Функция loadMigrations() читает встроенные в бинарник файлы миграций и сортирует их по номеру версии.
+
+	names, err := fs.Glob(migrationFiles, "assets/migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	for _, name := range names {
+		base := strings.TrimPrefix(name, "assets/migrations/")
+		version, err := strconv.Atoi(strings.SplitN(base, "_", 2)[0])
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: name must start with a version number", base)
+		}
+
+		data, err := migrationFiles.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: base, sql: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version == migrations[i-1].version {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", migrations[i-1].name, migrations[i].name)
+		}
+	}
+	return migrations, nil
+}
+
+func migrate(db *sql.DB) error {
+
+	// Функция migrate() обновляет схему БД при запуске бота.
+	// Примененные версии записываются в таблицу schema_migrations, каждая миграция выполняется в отдельной транзакции,
+	// поэтому при ошибке БД остается в состоянии последней успешной миграции.
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`create table if not exists schema_migrations(
+		version INTEGER PRIMARY KEY,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	var current int
+	if err = db.QueryRow("select coalesce(max(version), 0) from schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err = applyMigration(db, m); err != nil {
+			return fmt.Errorf("migrate: %s: %w", m.name, err)
+		}
+		slog.Info("migrate: applied", "migration", m.name)
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(m.sql); err != nil {
+		return err
+	}
+	if _, err = tx.Exec("insert into schema_migrations(version) values (?)", m.version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Схема БД до появления миграций (assets/schema.txt): чат записан в groupId, повторные /bind добавляли новые строки.
+const baselineSchema = `CREATE TABLE binds(
+   id INTEGER PRIMARY KEY AUTOINCREMENT,
+   groupId TEXT,
+   groupNumber TEXT
+)`
+
+func dumpSchema(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	rows, err := db.Query("select type || ' ' || name || ': ' || coalesce(sql, '') from sqlite_master where name not like 'sqlite_%' order by type, name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var schema []string
+	for rows.Next() {
+		var s string
+		if err = rows.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		schema = append(schema, s)
+	}
+	return schema
+}
+
+func TestMigrateBaseline(t *testing.T) {
+
+	// Миграции переносят ассоциации из исходной схемы без потерь: для чата остается его последняя группа.
+	// Повторный запуск миграций ничего не меняет.
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "baseline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err = db.Exec(baselineSchema); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range [][2]string{{"2000000001", "431-2"}, {"366661090", "162"}, {"2000000001", "431-1"}} {
+		if _, err = db.Exec("insert into binds(groupId, groupNumber) values (?, ?)", row[0], row[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 1; run <= 2; run++ {
+		before := dumpSchema(t, db)
+		if err = migrate(db); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+
+		var applied int
+		if err = db.QueryRow("select count(*) from schema_migrations").Scan(&applied); err != nil {
+			t.Fatal(err)
+		}
+		if applied != len(migrations) {
+			t.Errorf("run %d: %d migrations recorded, want %d", run, applied, len(migrations))
+		}
+		if after := dumpSchema(t, db); run == 2 && !reflect.DeepEqual(before, after) {
+			t.Errorf("second run changed the schema:\n%s\n---\n%s", strings.Join(before, "\n"), strings.Join(after, "\n"))
+		}
+
+		tests := []struct {
+			chat chatID
+			want []string
+		}{
+			{chatID{PLATFORM_VK, 2000000001}, []string{"431-1"}},
+			{chatID{PLATFORM_VK, 366661090}, []string{"162"}},
+		}
+		for _, tt := range tests {
+			groups, err := getChatGroups(db, tt.chat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(groups, tt.want) {
+				t.Errorf("run %d: %s groups = %q, want %q", run, tt.chat, groups, tt.want)
+			}
+		}
+	}
+
+	// После миграций ассоциации работают как обычно: к чату можно привязать еще одну группу.
+	if _, err = addBinding(db, chatID{PLATFORM_VK, 2000000001}, "431-2"); err != nil {
+		t.Fatal(err)
+	}
+	if groups, _ := getChatGroups(db, chatID{PLATFORM_VK, 2000000001}); len(groups) != 2 {
+		t.Errorf("groups after bind = %q", groups)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// На сколько дней вперед ищется следующая пара, если сегодня занятий больше нет. Хватает, чтобы пережить выходные и праздники.
+const NEXT_LESSON_HORIZON_DAYS = 7
+
+func formDuration(d time.Duration) string {
+
+	// Функция formDuration() записывает промежуток времени в часах и минутах: "1 ч 20 мин", "35 мин".
+
+	minutes := int(d.Round(time.Minute).Minutes())
+	if minutes < 60 {
+		return fmt.Sprintf("%d мин", minutes)
+	}
+	if minutes%60 == 0 {
+		return fmt.Sprintf("%d ч", minutes/60)
+	}
+	return fmt.Sprintf("%d ч %d мин", minutes/60, minutes%60)
+}
+
+func formNowMessage(groupNumber string, now time.Time, lessons []Lesson) string {
+
+	// Функция formNowMessage() сообщает, какая пара у группы идет сейчас и сколько до ее конца, какая будет следующей
+	// и когда закончатся занятия сегодня. Если сегодня занятий больше нет, называется ближайшая пара в следующие дни.
+	// lessons - отсортированные занятия группы с сегодняшнего дня.
+
+	var current, next []Lesson
+	var dayStart, dayEnd time.Time
+	var today = now.Format("20060102")
+
+	for _, l := range lessons {
+		if l.Start.Format("20060102") == today {
+			if dayStart.IsZero() || l.Start.Before(dayStart) {
+				dayStart = l.Start
+			}
+			if l.End.After(dayEnd) {
+				dayEnd = l.End
+			}
+		}
+		switch {
+		case !l.Start.After(now) && l.End.After(now):
+			current = append(current, l)
+
+		// Занятия подгрупп проходят одновременно, поэтому следующих пар может быть несколько.
+		case l.Start.After(now) && (len(next) == 0 || l.Start.Equal(next[0].Start)):
+			next = append(next, l)
+		}
+	}
+
+	var message = fmt.Sprintf("Группа %s, сейчас %s (%s).\n\n", groupNumber, now.Format("15:04"), getRuWeekDay(now))
+
+	if len(current) > 0 {
+		message += fmt.Sprintf("▶ Сейчас идет, до конца %s (до %s):\n", formDuration(current[0].End.Sub(now)), current[0].End.Format("15:04"))
+		for _, l := range current {
+			message += formLesson(l)
+		}
+	} else if dayStart.After(now) {
+		message += fmt.Sprintf("Занятия сегодня начнутся в %s.\n\n", dayStart.Format("15:04"))
+	} else if dayEnd.After(now) {
+		message += "☕ Сейчас перерыв.\n\n"
+	}
+
+	switch {
+	case len(next) > 0 && next[0].Start.Format("20060102") == today:
+		message += fmt.Sprintf("⏭ Следующая пара в %s, через %s:\n", next[0].Start.Format("15:04"), formDuration(next[0].Start.Sub(now)))
+		for _, l := range next {
+			message += formLesson(l)
+		}
+		message += fmt.Sprintf("Занятия сегодня закончатся в %s.", dayEnd.Format("15:04"))
+
+	case len(current) > 0:
+		message += "Это последняя пара на сегодня."
+
+	default:
+		if dayEnd.IsZero() {
+			message += "Сегодня занятий нет.\n"
+		} else {
+			message += fmt.Sprintf("Занятия на сегодня закончились в %s.\n", dayEnd.Format("15:04"))
+		}
+		if len(next) > 0 {
+			message += fmt.Sprintf("\n⏭ Следующая пара %s (%s) в %s:\n",
+				formCountdown(daysUntil(now, next[0].Start)), next[0].Start.Format("02.01"), next[0].Start.Format("15:04"))
+			for _, l := range next {
+				message += formLesson(l)
+			}
+		}
+	}
+	return message
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Роли администраторов бота.
+const ROLE_OWNER = "owner"
+const ROLE_MODERATOR = "moderator"
+
+// Уровень доступа к команде.
+type accessLevel int
+
+const (
+	accessAll       accessLevel = iota // Любой пользователь.
+	accessChatAdmin                    // Администраторы беседы, а также модераторы и владельцы бота. В личных сообщениях - любой пользователь.
+	accessModerator                    // Модераторы и владельцы бота.
+	accessOwner                        // Только владельцы бота.
+)
+
+// Упоминание пользователя ВК в тексте сообщения: "[id123|Имя Фамилия]" или "@id123".
+var vkMentionRe = regexp.MustCompile(`\[id(\d+)\|[^\]]*\]|@id(\d+)`)
+
+func (r *request) role() (string, error) {
+
+	// Функция role() возвращает роль автора сообщения. Владелец из настроек бота является владельцем всегда,
+	// поэтому бот нельзя оставить без владельца, и назначить первого владельца можно без доступа к БД.
+
+	if r.config.isOwner(r.user) {
+		return ROLE_OWNER, nil
+	}
+	return getRole(r.db, r.user)
+}
+
+func (r *request) allowed(level accessLevel) (bool, error) {
+
+	// Функция allowed() проверяет, может ли автор сообщения использовать команду с уровнем доступа level.
+
+	if level == accessAll {
+		return true, nil
+	}
+
+	role, err := r.role()
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case role == ROLE_OWNER:
+		return true, nil
+	case role == ROLE_MODERATOR && level <= accessModerator:
+		return true, nil
+	case level == accessChatAdmin:
+		return r.messenger.isChatAdmin(r.chat.ID, r.user.ID)
+	}
+	return false, nil
+}
+
+func (r *request) require(level accessLevel) bool {
+
+	// Функция require() проверяет права автора сообщения и, если их не хватает, сообщает об этом в чат.
+	// Используется для команд, часть действий которых доступна не всем, например для меню настроек.
+
+	allowed, err := r.allowed(level)
+	if err != nil {
+		r.replyError(err)
+		return false
+	}
+	if !allowed {
+		r.outcome = "denied"
+		if level == accessChatAdmin {
+			r.reply(noChatAdminAccess)
+		} else {
+			r.reply(noAccess)
+		}
+	}
+	return allowed
+}
+
+func userArgs(text string) []string {
+
+	// Функция userArgs() делит аргументы команды на части, заменяя упоминания пользователей ВК на "vk:123",
+	// так как имя в упоминании может содержать пробелы.
+
+	return strings.Fields(vkMentionRe.ReplaceAllString(text, "vk:$1$2"))
+}
+
+func parseUserArg(r *request, arg string) (chatID, bool) {
+
+	// Функция parseUserArg() разбирает пользователя, указанного в аргументах команды:
+	// "vk:123" или "tg:123" - с платформой, "123" - на платформе автора сообщения.
+
+	user := chatID{Platform: r.user.Platform}
+
+	if platform, id, found := strings.Cut(arg, ":"); found {
+		if platform != PLATFORM_VK && platform != PLATFORM_TELEGRAM {
+			return user, false
+		}
+		user.Platform = platform
+		arg = id
+	}
+
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id == 0 {
+		return user, false
+	}
+	user.ID = id
+	return user, true
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Время пар в ТУСУРе. Свободные окна в аудитории считаются по этой сетке.
+var pairTimes = [][2]string{
+	{"08:50", "10:25"},
+	{"10:40", "12:15"},
+	{"13:15", "14:50"},
+	{"15:00", "16:35"},
+	{"16:45", "18:20"},
+	{"18:30", "20:05"},
+	{"20:15", "21:50"},
+}
+
+func pairBounds(day time.Time, pair int) (time.Time, time.Time) {
+
+	// Функция pairBounds() возвращает время начала и окончания пары с номером pair (с нуля) в указанный день.
+
+	clock := func(value string) time.Time {
+		t, _ := time.Parse("15:04", value)
+		return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+	}
+	return clock(pairTimes[pair][0]), clock(pairTimes[pair][1])
+}
+
+func normalizeRoom(room string) string {
+
+	// Функция normalizeRoom() приводит название аудитории к виду для сравнения: "РК 418", "рк418" и "рк-418" совпадают.
+
+	return strings.NewReplacer("ё", "е", " ", "", "-", "", ".", "").Replace(strings.ToLower(room))
+}
+
+func hasRoom(l Lesson, room string) bool {
+	for _, r := range l.Rooms {
+		if normalizeRoom(r.String()) == room {
+			return true
+		}
+	}
+	return false
+}
+
+func findRooms(groups map[string][]Lesson, query string) (string, []string) {
+
+	// Функция findRooms() ищет аудиторию среди всех известных расписаний групп.
+	// Если аудитория найдена, возвращается ее название в том виде, в котором оно записано в календаре.
+	// Иначе возвращаются похожие аудитории, отличающиеся одним символом, - вероятно, пользователь опечатался.
+
+	query = normalizeRoom(query)
+
+	similar := make(map[string]bool)
+	for _, lessons := range groups {
+		for _, l := range lessons {
+			for _, r := range l.Rooms {
+				name := normalizeRoom(r.String())
+				if name == query {
+					return r.String(), nil
+				}
+				if levenshtein(name, query) <= 1 {
+					similar[r.String()] = true
+				}
+			}
+		}
+	}
+
+	rooms := make([]string, 0, len(similar))
+	for room := range similar {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return "", rooms
+}
+
+func freeWindows(day time.Time, lessons []groupedLesson) [][2]time.Time {
+
+	// Функция freeWindows() возвращает промежутки дня, в которые аудитория свободна.
+	// Пара считается свободной, если в ее время в аудитории нет занятий. Подряд идущие свободные пары объединяются в одно окно.
+
+	var windows [][2]time.Time
+	for pair := range pairTimes {
+		start, end := pairBounds(day, pair)
+
+		busy := false
+		for _, l := range lessons {
+			if l.Start.Before(end) && l.End.After(start) {
+				busy = true
+				break
+			}
+		}
+		if busy {
+			continue
+		}
+
+		if n := len(windows); n > 0 && pair > 0 {
+			if _, prevEnd := pairBounds(day, pair-1); windows[n-1][1].Equal(prevEnd) {
+				windows[n-1][1] = end
+				continue
+			}
+		}
+		windows = append(windows, [2]time.Time{start, end})
+	}
+	return windows
+}
+
+func formRoomMessage(room string, day time.Time, lessons []groupedLesson) string {
+
+	// Функция formRoomMessage() формирует сообщение о занятости аудитории на день: занятия в ней и свободные окна.
+
+	var message = fmt.Sprintf("Аудитория %s на %s (%s).\n\n", room, day.Format("02.01.2006"), getRuWeekDay(day))
+
+	if len(lessons) == 0 {
+		message += "Занятий нет, аудитория свободна весь день 🥳\n"
+	}
+	for _, l := range lessons {
+		message += fmt.Sprintf("🔴 %s-%s %s\n", l.Start.Format("15:04"), l.End.Format("15:04"), formLessonTitle(l.Lesson))
+		message += fmt.Sprintf(" 👥 Группы: %s\n", strings.Join(l.Groups, ", "))
+		if len(l.Teachers) > 0 {
+			message += fmt.Sprintf(" 👨 Преподаватель: %s\n", strings.Join(l.Teachers, ", "))
+		}
+	}
+
+	if windows := freeWindows(day, lessons); len(lessons) > 0 && len(windows) > 0 {
+		message += "\n"
+		for _, w := range windows {
+			message += fmt.Sprintf("🟢 Свободна %s-%s\n", w[0].Format("15:04"), w[1].Format("15:04"))
+		}
+	}
+	return message + roomCachedNote
+}
+
+func formRoomAt(room string, at time.Time, lessons []groupedLesson) string {
+
+	// Функция formRoomAt() отвечает, свободна ли аудитория в указанное время, и до какого времени.
+
+	for _, l := range lessons {
+		if !at.Before(l.Start) && at.Before(l.End) {
+			return fmt.Sprintf("🔴 В %s аудитория %s занята до %s: %s, группы %s.\n",
+				at.Format("15:04"), room, l.End.Format("15:04"), formLessonTitle(l.Lesson), strings.Join(l.Groups, ", ")) + roomCachedNote
+		}
+	}
+
+	for _, l := range lessons {
+		if l.Start.After(at) {
+			return fmt.Sprintf("🟢 В %s аудитория %s свободна до %s.\n", at.Format("15:04"), room, l.Start.Format("15:04")) + roomCachedNote
+		}
+	}
+	return fmt.Sprintf("🟢 В %s аудитория %s свободна до конца дня.\n", at.Format("15:04"), room) + roomCachedNote
+}
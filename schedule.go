@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Занятия из календаря группы вместе со временем изменения файла, из которого они получены.
+type parsedCalendar struct {
+	modTime time.Time
+	lessons []Lesson
+}
+
+// ScheduleService отвечает за получение занятий групп. Каждый вызов возвращает новый срез,
+// поэтому обработчик сообщений и рассылка по расписанию могут пользоваться им одновременно.
+type ScheduleService struct {
+	cache  *scheduleCache
+	groups *groupDirectory
+
+	// Парсер ics-golang использует глобальные настройки и счетчики, поэтому разбор файлов выполняется под мьютексом.
+	parseMu sync.Mutex
+
+	mu        sync.Mutex
+	calendars map[string]parsedCalendar
+}
+
+func NewScheduleService(cache *scheduleCache, groups *groupDirectory) *ScheduleService {
+	return &ScheduleService{
+		cache:     cache,
+		groups:    groups,
+		calendars: make(map[string]parsedCalendar),
+	}
+}
+
+func (s *ScheduleService) lessons(groupNumber string) ([]Lesson, error) {
+
+	// Функция lessons() возвращает все занятия из календаря группы.
+	// Факультет и ссылка на расписание берутся из справочника групп, файл - из кеша расписаний,
+	// а повторный разбор выполняется только если файл изменился.
+
+	group, _, ok := s.groups.resolve(groupNumber)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errGroupNotFound, groupNumber)
+	}
+
+	fileName, err := s.cache.get(group)
+	if err != nil {
+		return nil, err
+	}
+	return s.parseFile(group.Slug, fileName)
+}
+
+func (s *ScheduleService) parseFile(name string, fileName string) ([]Lesson, error) {
+
+	// Функция parseFile() возвращает занятия из файла расписания, разбирая его заново только если файл изменился.
+
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	cal, ok := s.calendars[name]
+	s.mu.Unlock()
+	if ok && cal.modTime.Equal(info.ModTime()) {
+		return cal.lessons, nil
+	}
+
+	lessons, err := s.parse(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.calendars[name] = parsedCalendar{modTime: info.ModTime(), lessons: lessons}
+	s.mu.Unlock()
+	return lessons, nil
+}
+
+func (s *ScheduleService) parse(fileName string) ([]Lesson, error) {
+	s.parseMu.Lock()
+	defer s.parseMu.Unlock()
+
+	started := time.Now()
+	defer func() {
+		parseSeconds.Observe(time.Since(started).Seconds())
+	}()
+	return parseSchedule(fileName)
+}
+
+func (s *ScheduleService) CachedGroups() map[string][]Lesson {
+
+	// Функция CachedGroups() возвращает занятия всех групп, расписания которых уже есть в кеше, не обращаясь к сайту.
+	// Используется для поиска по всем известным расписаниям: преподавателей, аудиторий.
+
+	groups := make(map[string][]Lesson)
+	for _, name := range s.cache.cached() {
+		if strings.HasPrefix(name, LECTURER_PREFIX) {
+			continue
+		}
+		lessons, err := s.parseFile(name, s.cache.path(name))
+		if err != nil {
+			continue
+		}
+		groups[s.groups.name(name)] = lessons
+	}
+	return groups
+}
+
+func (s *ScheduleService) ResolveGroup(groupNumber string) (string, []string, bool) {
+
+	// Функция ResolveGroup() проверяет номер группы по справочнику и возвращает его в том виде, в котором он записан на сайте.
+	// Если группа не найдена, возвращаются похожие номера для подсказки.
+
+	group, suggestions, ok := s.groups.resolve(groupNumber)
+	return group.Name, suggestions, ok
+}
+
+func (s *ScheduleService) GroupFaculty(groupNumber string) string {
+
+	// Функция GroupFaculty() возвращает факультет группы из ссылки на ее расписание, например "fsu".
+
+	group, _, _ := s.groups.resolve(groupNumber)
+	return group.Faculty
+}
+
+func (s *ScheduleService) LessonsFor(groupNumber string, day time.Time) ([]Lesson, error) {
+
+	// Функция LessonsFor() возвращает отсортированные по времени начала пары группы на указанный день.
+
+	return s.LessonsBetween(groupNumber, day, day)
+}
+
+func (s *ScheduleService) LessonsBetween(groupNumber string, from time.Time, to time.Time) ([]Lesson, error) {
+
+	// Функция LessonsBetween() возвращает отсортированные по времени начала пары группы с дня from по день to включительно.
+
+	all, err := s.lessons(groupNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	first, last := from.Format("20060102"), to.Format("20060102")
+	lessons := make([]Lesson, 0)
+	for _, l := range all {
+		// Проверка, попадает ли дата начала занятия в указанный промежуток. Даты в таком формате можно сравнивать как строки.
+		if date := l.Start.Format("20060102"); date >= first && date <= last {
+			lessons = append(lessons, l)
+		}
+	}
+
+	sortLessons(lessons)
+	return lessons, nil
+}
+
+func (s *ScheduleService) AllLessons(groupNumber string) ([]Lesson, error) {
+
+	// Функция AllLessons() возвращает все занятия группы из календаря, отсортированные по времени начала.
+
+	all, err := s.lessons(groupNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	lessons := append([]Lesson(nil), all...)
+	sortLessons(lessons)
+	return lessons, nil
+}
+
+func sortLessons(lessons []Lesson) {
+	sort.SliceStable(lessons, func(i, j int) bool {
+		return lessons[i].Start.Before(lessons[j].Start)
+	})
+}
+
+func filterLessons(lessons []Lesson, from time.Time, to time.Time) []Lesson {
+
+	// Функция filterLessons() возвращает занятия, начинающиеся в промежутке [from, to).
+
+	var filtered []Lesson
+	for _, l := range lessons {
+		if !l.Start.Before(from) && l.Start.Before(to) {
+			filtered = append(filtered, l)
+		}
+	}
+	return filtered
+}
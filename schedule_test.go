@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testCalendarHeader = "BEGIN:VCALENDAR\nVERSION:2.0\n"
+const testCalendarFooter = "END:VCALENDAR\n"
+
+func testEvent(start string, end string, summary string, description string, location string) string {
+	return "BEGIN:VEVENT\n" +
+		"DTEND;TZID=Asia/Novosibirsk;VALUE=DATE-TIME:" + end + "\n" +
+		"DTSTART;TZID=Asia/Novosibirsk;VALUE=DATE-TIME:" + start + "\n" +
+		"DESCRIPTION:" + description + "\n" +
+		"SUMMARY:" + summary + "\n" +
+		"LOCATION:" + location + "\n" +
+		"END:VEVENT\n"
+}
+
+func newTestSchedule(t *testing.T, calendars map[string]string) *ScheduleService {
+
+	// Функция newTestSchedule() собирает ScheduleService над временным каталогом, в котором расписания групп
+	// уже закешированы, а справочник групп свежий, поэтому сервис не обращается к сайту.
+
+	t.Helper()
+	dir := t.TempDir()
+
+	var directory = groupDirectoryData{FetchedAt: time.Now()}
+	for name, content := range calendars {
+		directory.Groups = append(directory.Groups, groupInfo{Name: name, Faculty: "fsu", Slug: name})
+
+		if err := os.WriteFile(filepath.Join(dir, name+".ics"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		meta, _ := json.Marshal(cacheEntry{FetchedAt: time.Now()})
+		if err := os.WriteFile(filepath.Join(dir, name+".json"), meta, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, _ := json.Marshal(directory)
+	if err := os.WriteFile(filepath.Join(dir, GROUP_DIRECTORY_FILE), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Адрес сайта недоступен, чтобы случайное обращение к нему было заметно по ошибке.
+	return NewScheduleService(
+		newScheduleCache(dir, "http://127.0.0.1:0", time.Hour),
+		newGroupDirectory(dir, "http://127.0.0.1:0"),
+	)
+}
+
+func TestLessonsForParallelGroups(t *testing.T) {
+
+	// Одновременные запросы расписания разных групп не должны получать занятия чужой группы.
+
+	fixture, err := os.ReadFile("groups/162.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calendars := map[string]string{"162": string(fixture)}
+	for i := 1; i <= 8; i++ {
+		name := fmt.Sprintf("43%d-1", i)
+		calendars[name] = testCalendarHeader +
+			testEvent("20220906T084500", "20220906T102000", "Предмет группы "+name, "Лекция\\, Иванов И.И.", "рк "+name) +
+			testEvent("20220906T104000", "20220906T121500", "Практика группы "+name, "Практика\\, Петров П.П.", "рк "+name) +
+			testCalendarFooter
+	}
+	schedule := newTestSchedule(t, calendars)
+	day := time.Date(2022, 9, 6, 0, 0, 0, 0, scheduleLocation)
+
+	var wg sync.WaitGroup
+	for round := 0; round < 20; round++ {
+		for name := range calendars {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+
+				lessons, err := schedule.LessonsFor(name, day)
+				if err != nil {
+					t.Errorf("%s: %v", name, err)
+					return
+				}
+				if len(lessons) == 0 {
+					t.Errorf("%s: no lessons", name)
+				}
+				for _, l := range lessons {
+					if name == "162" && strings.Contains(l.Subject, "группы") {
+						t.Errorf("162 got foreign lesson %q", l.Subject)
+					}
+					if name != "162" && !strings.HasSuffix(l.Subject, "группы "+name) {
+						t.Errorf("%s got foreign lesson %q", name, l.Subject)
+					}
+				}
+
+				message := formMessage(name, day, lessons, scheduleView{})
+				if !strings.Contains(message, "Расписание группы "+name+" ") {
+					t.Errorf("%s: wrong header in %q", name, message)
+				}
+			}(name)
+		}
+	}
+	wg.Wait()
+}
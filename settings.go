@@ -0,0 +1,497 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Настройка чата, включающая краткий вид расписания: каждая пара - одной строкой.
+const SETTING_COMPACT = "compact"
+
+// Настройка чата с номером подгруппы, занятия которой показываются в расписании. Без нее показываются все подгруппы.
+// В личных сообщениях чат совпадает с пользователем, поэтому подгруппу можно выбрать и для себя.
+const SETTING_SUBGROUP = "subgroup"
+
+// Подгрупп в группах ТУСУРа обычно две, изредка - три.
+const MAX_SUBGROUP = 3
+
+// Клавиатура быстрых команд, которая показывается под полем ввода после /start, /help и привязки группы,
+// чтобы расписание можно было получить без набора команд.
+var quickKeyboard = &keyboard{rows: [][]button{
+	{{"Сегодня", "расписос"}, {"Завтра", "расписос на завтра"}},
+	{{"Неделя", "расписос на неделю"}, {"Следующая пара", "следующая пара"}},
+	{{"⚙ Настройки", "/settings"}},
+}}
+
+// Время рассылки, которое можно включить кнопкой в меню настроек. Другое время задается командой /notify.
+// Вместе со стандартным временем из настроек бота в меню получается не больше 10 кнопок - столько ВК разрешает во встроенной клавиатуре.
+var notifyPresets = []notifySlot{
+	{"07:00", NOTIFY_TODAY}, {"08:00", NOTIFY_TODAY},
+	{"20:00", NOTIFY_TOMORROW}, {"21:00", NOTIFY_TOMORROW},
+}
+
+// Вид расписания в сообщениях чата.
+type scheduleView struct {
+	compact  bool
+	subgroup int // 0 - показывать занятия всех подгрупп.
+	filters  []lessonFilter
+}
+
+// Число занятий, скрытых из расписания чата, по причинам.
+type hiddenLessons struct {
+	subgroup int
+	filtered int
+}
+
+func getScheduleView(db *sql.DB, chat chatID) scheduleView {
+
+	// Функция getScheduleView() читает настройки вида расписания чата. Если настройки или фильтры не удалось прочитать,
+	// расписание показывается без них: лучше лишнее занятие, чем пропавшее.
+
+	subgroup, err := getChatSetting(db, chat, SETTING_SUBGROUP, "0")
+	if err != nil {
+		slog.Warn("get schedule view", "chat", chat, "err", err)
+	}
+	compact, err := getChatSetting(db, chat, SETTING_COMPACT, "off")
+	if err != nil {
+		slog.Warn("get schedule view", "chat", chat, "err", err)
+	}
+	filters, err := getLessonFilters(db, chat)
+	if err != nil {
+		slog.Warn("get lesson filters", "chat", chat, "err", err)
+	}
+
+	view := scheduleView{compact: compact == "on", filters: filters}
+	view.subgroup, _ = strconv.Atoi(subgroup)
+	return view
+}
+
+func (v scheduleView) apply(lessons []Lesson) ([]Lesson, hiddenLessons) {
+
+	// Функция apply() оставляет занятия, которые нужно показать чату: занятия всей группы и выбранной подгруппы,
+	// не скрытые фильтрами чата. Параллельные занятия без номера подгруппы показываются всем подгруппам.
+	// Скрытые занятия подсчитываются, чтобы сообщить о них в расписании.
+
+	var hidden hiddenLessons
+	if v.subgroup == 0 && len(v.filters) == 0 {
+		return lessons, hidden
+	}
+
+	shown := make([]Lesson, 0, len(lessons))
+	for _, l := range lessons {
+		if l.Subgroup != 0 && v.subgroup != 0 && l.Subgroup != v.subgroup {
+			hidden.subgroup++
+			continue
+		}
+		if hide, _ := matchFilters(v.filters, l); hide {
+			hidden.filtered++
+			continue
+		}
+		shown = append(shown, l)
+	}
+	return shown, hidden
+}
+
+func (v scheduleView) hiddenNote(hidden hiddenLessons) string {
+	var note = ""
+	if hidden.subgroup > 0 {
+		note += fmt.Sprintf(subgroupHiddenMsg, v.subgroup, hidden.subgroup)
+	}
+	if hidden.filtered > 0 {
+		note += fmt.Sprintf(filterHiddenMsg, hidden.filtered)
+	}
+	if note != "" {
+		note += "\n"
+	}
+	return note
+}
+
+func (v scheduleView) lesson(l Lesson) string {
+	if v.compact {
+		return formLessonCompact(l)
+	}
+	return formLesson(l)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "вкл"
+	}
+	return "выкл"
+}
+
+func subgroupName(subgroup int) string {
+	if subgroup == 0 {
+		return "все"
+	}
+	return strconv.Itoa(subgroup)
+}
+
+func parseSubgroupArg(arg string) (int, bool) {
+
+	// Функция parseSubgroupArg() разбирает номер подгруппы из команды: "1", "2" или "все".
+
+	switch arg {
+	case "все", "всё", "all", "0":
+		return 0, true
+	}
+	subgroup, err := strconv.Atoi(arg)
+	return subgroup, err == nil && subgroup >= 1 && subgroup <= MAX_SUBGROUP
+}
+
+func viewName(compact bool) string {
+	if compact {
+		return "краткий"
+	}
+	return "подробный"
+}
+
+func notifyDayArg(day string) string {
+
+	// Функция notifyDayArg() возвращает день рассылки так, как он указывается в команде /notify.
+
+	if day == NOTIFY_TOMORROW {
+		return "завтра"
+	}
+	return "сегодня"
+}
+
+func formNotifyTimes(slots []notifySlot) string {
+	var times []string
+	for _, s := range slots {
+		times = append(times, s.Time+" на "+notifyDayArg(s.Day))
+	}
+	return strings.Join(times, ", ")
+}
+
+func chatNotifySlots(r *request) ([]notifySlot, bool, error) {
+
+	// Функция chatNotifySlots() возвращает время, в которое чат получает расписание: собственное или стандартное.
+	// Второй результат - выключена ли рассылка в чат.
+
+	slots, err := getNotifySlots(r.db, r.chat)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(slots) == 0 {
+		return []notifySlot{{r.config.MorningTime, NOTIFY_TODAY}, {r.config.EveningTime, NOTIFY_TOMORROW}}, false, nil
+	}
+	if slots[0].Day == NOTIFY_OFF {
+		return nil, true, nil
+	}
+	return slots, false, nil
+}
+
+func handleSettings(r *request) {
+
+	// Команда /settings показывает меню настроек чата с кнопками. Кнопки меню отправляют ту же команду с аргументами:
+	// 	/settings рассылка - экран времени рассылки, /settings группы - экран привязанных групп;
+	// 	/settings рассылка 08:00 сегодня - включить или выключить рассылку в это время, выкл/сброс - выключить все или вернуть стандартное время;
+	// 	/settings напоминания вкл/выкл, /settings вид краткий/подробный, /settings подгруппа 1/2/все, /settings отвязать *номер_группы*.
+	// Просматривать настройки может любой участник беседы, а менять - только администраторы.
+
+	if len(r.args) == 0 {
+		sendSettingsMenu(r)
+		return
+	}
+
+	section, args := r.args[0], r.args[1:]
+	if section == "рассылка" && len(args) == 0 {
+		sendNotifyMenu(r)
+		return
+	}
+	if section == "группы" && len(args) == 0 {
+		sendGroupsMenu(r)
+		return
+	}
+	if len(args) == 0 {
+		r.reply(settingsUsage)
+		return
+	}
+
+	if !r.require(accessChatAdmin) {
+		return
+	}
+
+	var err error
+	switch {
+	case section == "рассылка" && (args[0] == "выкл" || args[0] == "сброс"):
+		err = resetNotifySlots(r.db, r.chat, args[0] == "выкл")
+
+	case section == "рассылка":
+		slot, ok := parseNotifySlot(args)
+		if !ok {
+			r.reply(settingsUsage)
+			return
+		}
+		err = toggleNotifySlot(r, slot)
+
+	case section == "напоминания" && (args[0] == "вкл" || args[0] == "выкл"):
+		value := "off"
+		if args[0] == "вкл" {
+			value = "on"
+		}
+		err = setChatSetting(r.db, r.chat, SETTING_EXAM_REMINDERS, value)
+
+	case section == "вид" && (args[0] == "краткий" || args[0] == "подробный"):
+		value := "off"
+		if args[0] == "краткий" {
+			value = "on"
+		}
+		err = setChatSetting(r.db, r.chat, SETTING_COMPACT, value)
+
+	case section == "подгруппа":
+		subgroup, ok := parseSubgroupArg(args[0])
+		if !ok {
+			r.reply(settingsUsage)
+			return
+		}
+		err = setChatSetting(r.db, r.chat, SETTING_SUBGROUP, strconv.Itoa(subgroup))
+
+	case section == "отвязать":
+		groups, err := getChatGroups(r.db, r.chat)
+		if err != nil {
+			r.replyError(err)
+			return
+		}
+		groupNumber, ok := boundGroup(groups, strings.Join(args, " "))
+		if !ok {
+			r.reply(fmt.Sprintf(notBoundMsg, strings.Join(args, " "), strings.Join(groups, ", ")))
+			return
+		}
+		if _, err = rmBinding(r.db, r.chat, groupNumber); err != nil {
+			r.replyError(err)
+			return
+		}
+		sendGroupsMenu(r)
+		return
+
+	default:
+		r.reply(settingsUsage)
+		return
+	}
+
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	if section == "рассылка" {
+		sendNotifyMenu(r)
+	} else {
+		sendSettingsMenu(r)
+	}
+}
+
+func toggleNotifySlot(r *request, slot notifySlot) error {
+
+	// Функция toggleNotifySlot() включает рассылку в указанное время, а если она уже включена - выключает.
+	// В одно время чат получает только одну рассылку, поэтому для уже включенного времени с другим днем меняется день.
+	// Стандартное время при этом сохраняется как собственное время чата, чтобы остальные рассылки не пропали.
+
+	slots, _, err := chatNotifySlots(r)
+	if err != nil {
+		return err
+	}
+
+	var updated []notifySlot
+	var found bool
+	for _, s := range slots {
+		if s.Time != slot.Time {
+			updated = append(updated, s)
+			continue
+		}
+		found = true
+		if s.Day != slot.Day {
+			updated = append(updated, slot)
+		}
+	}
+	if !found {
+		updated = append(updated, slot)
+	}
+	return setNotifySlots(r.db, r.chat, updated)
+}
+
+func sendSettingsMenu(r *request) {
+
+	// Функция sendSettingsMenu() отправляет главный экран меню настроек с текущими настройками чата.
+
+	groups, err := getChatGroups(r.db, r.chat)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	slots, off, err := chatNotifySlots(r)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	value, err := getChatSetting(r.db, r.chat, SETTING_EXAM_REMINDERS, "off")
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+	reminders := value == "on"
+	view := getScheduleView(r.db, r.chat)
+
+	var message = "⚙ Настройки чата\n\n"
+	if len(groups) > 0 {
+		message += fmt.Sprintf("👥 Группы: %s\n", strings.Join(groups, ", "))
+	} else {
+		message += "👥 Группа не привязана. Привязать: /bind *номер_группы*\n"
+	}
+
+	if off || len(slots) == 0 {
+		message += "🕛 Рассылка расписания: выключена\n"
+	} else {
+		message += fmt.Sprintf("🕛 Рассылка расписания: %s\n", formNotifyTimes(slots))
+	}
+	message += fmt.Sprintf("⏰ Напоминания об экзаменах: %s\n", onOff(reminders))
+	message += fmt.Sprintf("📄 Вид расписания: %s\n", viewName(view.compact))
+	message += fmt.Sprintf("🔀 Подгруппа: %s\n", subgroupName(view.subgroup))
+	message += fmt.Sprintf("🚫 Фильтры занятий: %d, список - /filter\n", len(view.filters))
+	message += "\n" + settingsHintMsg
+
+	kb := &keyboard{inline: true, rows: [][]button{
+		{{"🕛 Время рассылки", "/settings рассылка"}},
+		{{"⏰ Напоминания: " + onOff(reminders), "/settings напоминания " + onOff(!reminders)}},
+		{{"📄 Вид: " + viewName(view.compact), "/settings вид " + viewName(!view.compact)}},
+		{{"🔀 Подгруппа: " + subgroupName(view.subgroup), "/settings подгруппа " + subgroupName(nextSubgroup(view.subgroup))}},
+	}}
+	if len(groups) > 0 {
+		kb.rows = append(kb.rows, []button{{"👥 Группы", "/settings группы"}})
+	}
+	r.replyKeyboard(message, kb)
+}
+
+func sendGroupsMenu(r *request) {
+
+	// Функция sendGroupsMenu() отправляет экран меню с группами чата, каждую из которых можно отвязать.
+	// Группы вынесены на отдельный экран, потому что ВК разрешает во встроенной клавиатуре не больше 6 рядов:
+	// здесь MAX_CHAT_GROUPS рядов с группами и ряд "Назад".
+
+	groups, err := getChatGroups(r.db, r.chat)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+
+	var message = "👥 Группы чата\n\n"
+	if len(groups) == 0 {
+		message += "Группа не привязана. Привязать: /bind *номер_группы*"
+	} else {
+		message += fmt.Sprintf("Чат получает расписание групп: %s.\n\n", strings.Join(groups, ", ")) + groupsMenuHintMsg
+	}
+
+	kb := &keyboard{inline: true}
+	for _, g := range groups {
+		kb.rows = append(kb.rows, []button{{"✖ Отвязать " + g, "/settings отвязать " + g}})
+	}
+	kb.rows = append(kb.rows, []button{{"← Назад", "/settings"}})
+	r.replyKeyboard(message, kb)
+}
+
+func nextSubgroup(subgroup int) int {
+
+	// Кнопка подгруппы в меню переключает по кругу: все, 1, 2, все. Третья подгруппа выбирается командой /subgroup 3.
+
+	if subgroup >= 2 {
+		return 0
+	}
+	return subgroup + 1
+}
+
+func handleSubgroup(r *request) {
+
+	// Команда /subgroup *1/2/все* выбирает подгруппу, занятия которой показываются в расписании чата.
+	// Без аргументов сообщает текущую настройку. Менять подгруппу в беседе могут только ее администраторы.
+
+	if len(r.args) == 0 {
+		r.reply(fmt.Sprintf(subgroupInfoMsg, subgroupName(getScheduleView(r.db, r.chat).subgroup)))
+		return
+	}
+
+	subgroup, ok := parseSubgroupArg(r.args[0])
+	if !ok {
+		r.reply(subgroupUsage)
+		return
+	}
+	if !r.require(accessChatAdmin) {
+		return
+	}
+	if err := setChatSetting(r.db, r.chat, SETTING_SUBGROUP, strconv.Itoa(subgroup)); err != nil {
+		r.replyError(err)
+		return
+	}
+
+	if subgroup == 0 {
+		r.reply(subgroupAllMsg)
+	} else {
+		r.reply(fmt.Sprintf(subgroupSetMsg, subgroup))
+	}
+}
+
+func sendNotifyMenu(r *request) {
+
+	// Функция sendNotifyMenu() отправляет экран меню с временем рассылки. Включенное время отмечено галочкой.
+
+	slots, off, err := chatNotifySlots(r)
+	if err != nil {
+		r.replyError(err)
+		return
+	}
+
+	enabled := make(map[notifySlot]bool)
+	for _, s := range slots {
+		enabled[s] = true
+	}
+
+	// Кроме готовых вариантов, в меню показывается стандартное время из настроек бота.
+	presets := append([]notifySlot{{r.config.MorningTime, NOTIFY_TODAY}, {r.config.EveningTime, NOTIFY_TOMORROW}}, notifyPresets...)
+	sort.SliceStable(presets, func(i, j int) bool {
+		return presets[i].Time < presets[j].Time
+	})
+
+	var today, tomorrow []button
+	seen := make(map[notifySlot]bool)
+	for _, p := range presets {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+
+		label := p.Time
+		if enabled[p] {
+			label = "✅ " + label
+		}
+		b := button{label, fmt.Sprintf("/settings рассылка %s %s", p.Time, notifyDayArg(p.Day))}
+		if p.Day == NOTIFY_TOMORROW {
+			tomorrow = append(tomorrow, b)
+		} else {
+			today = append(today, b)
+		}
+	}
+
+	var message = "🕛 Время рассылки расписания\n\n"
+	if off || len(slots) == 0 {
+		message += "Сейчас рассылка выключена.\n\n"
+	} else {
+		message += fmt.Sprintf("Сейчас: %s.\n\n", formNotifyTimes(slots))
+	}
+	message += notifyMenuHintMsg
+
+	kb := &keyboard{inline: true}
+	for _, row := range [][]button{today, tomorrow} {
+		if len(row) > 0 {
+			kb.rows = append(kb.rows, row)
+		}
+	}
+	kb.rows = append(kb.rows,
+		[]button{{"Выключить", "/settings рассылка выкл"}, {"По умолчанию", "/settings рассылка сброс"}},
+		[]button{{"← Назад", "/settings"}},
+	)
+	r.replyKeyboard(message, kb)
+}
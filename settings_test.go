@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSettingsToggleNotifySlot(t *testing.T) {
+
+	// Кнопка с уже включенным временем, но другим днем меняет день рассылки, а не добавляет вторую рассылку в то же время.
+
+	db := newTestDB(t)
+	ms := newFakeMessenger()
+	chat := chatID{PLATFORM_VK, 2000000001}
+	user := chatID{PLATFORM_VK, 7}
+	if err := setNotifySlots(db, chat, []notifySlot{{"07:00", NOTIFY_TOMORROW}, {"21:00", NOTIFY_TOMORROW}}); err != nil {
+		t.Fatal(err)
+	}
+
+	rt := newRouter(botCommands()...)
+	r := &request{
+		chat:      chat,
+		user:      user,
+		config:    &Config{MorningTime: "08:00", EveningTime: "20:00", Location: scheduleLocation},
+		db:        db,
+		messenger: ms,
+	}
+
+	tests := []struct {
+		text string
+		want []notifySlot
+	}{
+		{"/settings рассылка 07:00 сегодня", []notifySlot{{"07:00", NOTIFY_TODAY}, {"21:00", NOTIFY_TOMORROW}}},
+		{"/settings рассылка 07:00 сегодня", []notifySlot{{"21:00", NOTIFY_TOMORROW}}},
+		{"/settings рассылка 7:00 завтра", []notifySlot{{"07:00", NOTIFY_TOMORROW}, {"21:00", NOTIFY_TOMORROW}}},
+	}
+	for _, tt := range tests {
+		if !rt.dispatch(r, tt.text) {
+			t.Fatalf("%q is not dispatched", tt.text)
+		}
+		slots, err := getNotifySlots(db, chat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(slots, tt.want) {
+			t.Errorf("after %q slots = %v, want %v", tt.text, slots, tt.want)
+		}
+	}
+
+	for _, message := range ms.messages(chat.ID) {
+		if strings.Contains(message, unhandledErrMsg) {
+			t.Errorf("got error reply %q", message)
+		}
+	}
+}
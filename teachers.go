@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Расписания преподавателей хранятся в том же кеше, что и расписания групп, но под именами с этим префиксом.
+const LECTURER_PREFIX = "lecturer-"
+
+// Файл в каталоге кеша, в котором запоминаются найденные на сайте страницы преподавателей.
+const LECTURER_LINKS_FILE = "lecturers.json"
+
+// Найденная страница преподавателя: ссылка на календарь и имя расписания в кеше.
+type lecturerLink struct {
+	URL  string `json:"url"`
+	Name string `json:"name"`
+}
+
+// Занятие из расписаний нескольких групп. Лекция у потока проходит у нескольких групп сразу,
+// поэтому при поиске по преподавателю или аудитории группы собираются в одно занятие.
+type groupedLesson struct {
+	Lesson
+	Groups []string
+}
+
+func normalizeName(name string) string {
+
+	// Функция normalizeName() приводит имя к виду для сравнения: нижний регистр, "ё" заменяется на "е", без точек и пробелов.
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer("ё", "е", ".", "", " ", "").Replace(name)
+}
+
+func splitTeacherName(teacher string) (string, string) {
+
+	// Функция splitTeacherName() делит имя вида "Дубинин Д.В." на нормализованные фамилию ("дубинин") и инициалы ("дв").
+
+	fields := strings.Fields(teacher)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	return normalizeName(fields[0]), normalizeName(strings.Join(fields[1:], ""))
+}
+
+func surnameDistance(query string, surname string) int {
+
+	// Функция surnameDistance() оценивает, насколько фамилия похожа на искомую: 0 - совпадает,
+	// 1 - искомая строка является началом фамилии, больше - число опечаток плюс один.
+	// Для коротких строк опечатки не допускаются, иначе под запрос попадает слишком много фамилий.
+
+	switch {
+	case query == surname:
+		return 0
+	case len([]rune(query)) >= 4 && strings.HasPrefix(surname, query):
+		return 1
+	}
+
+	allowed := 1
+	if len([]rune(query)) >= 8 {
+		allowed = 2
+	} else if len([]rune(query)) < 5 {
+		allowed = 0
+	}
+	if d := levenshtein(query, surname); d <= allowed {
+		return d + 1
+	}
+	return -1
+}
+
+func findTeachers(groups map[string][]Lesson, query string) []string {
+
+	// Функция findTeachers() находит преподавателей из известных расписаний, подходящих под запрос "Фамилия [И.О.]".
+	// Если есть точные совпадения фамилии, варианты с опечатками не предлагаются.
+
+	surname, initials := splitTeacherName(query)
+	if surname == "" {
+		return nil
+	}
+
+	best := -1
+	found := make(map[string]bool)
+	for _, lessons := range groups {
+		for _, l := range lessons {
+			for _, teacher := range l.Teachers {
+				s, i := splitTeacherName(teacher)
+				if !strings.HasPrefix(i, initials) {
+					continue
+				}
+				d := surnameDistance(surname, s)
+				if d < 0 || (best >= 0 && d > best) {
+					continue
+				}
+				if d < best || best < 0 {
+					best = d
+					found = make(map[string]bool)
+				}
+				found[teacher] = true
+			}
+		}
+	}
+
+	teachers := make([]string, 0, len(found))
+	for teacher := range found {
+		teachers = append(teachers, teacher)
+	}
+	sort.Strings(teachers)
+	return teachers
+}
+
+func hasTeacher(l Lesson, teacher string) bool {
+	for _, t := range l.Teachers {
+		if t == teacher {
+			return true
+		}
+	}
+	return false
+}
+
+func collectLessons(groups map[string][]Lesson, day time.Time, match func(Lesson) bool) []groupedLesson {
+
+	// Функция collectLessons() собирает занятия на день, удовлетворяющие условию match, из расписаний всех известных групп.
+	// Одинаковые занятия разных групп (предмет, вид, время, аудитории) объединяются в одно со списком групп.
+
+	date := day.Format("20060102")
+
+	names := make([]string, 0, len(groups))
+	for groupNumber := range groups {
+		names = append(names, groupNumber)
+	}
+	sort.Strings(names)
+
+	var lessons []groupedLesson
+	for _, groupNumber := range names {
+		for _, l := range groups[groupNumber] {
+			if l.Start.Format("20060102") != date || !match(l) {
+				continue
+			}
+
+			merged := false
+			for i := range lessons {
+				if sameSlot(lessons[i].Lesson, l) && sameRooms(lessons[i].Rooms, l.Rooms) {
+					lessons[i].Groups = append(lessons[i].Groups, groupNumber)
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				lessons = append(lessons, groupedLesson{Lesson: l, Groups: []string{groupNumber}})
+			}
+		}
+	}
+
+	sort.SliceStable(lessons, func(i, j int) bool {
+		return lessons[i].Start.Before(lessons[j].Start)
+	})
+	return lessons
+}
+
+func (c *scheduleCache) lecturerURL(teacher string) (string, string, error) {
+
+	// Функция lecturerURL() ищет страницу преподавателя через общий поиск сайта расписания.
+	// Поиск перенаправляет на страницу найденного преподавателя (/faculties/*факультет*/lecturers/*id*),
+	// а календарь .ics доступен по тому же адресу, что и для групп. Возвращается ссылка на календарь и имя для кеша.
+
+	query := url.Values{"search[common]": {teacher}}
+	resp, err := c.client.Get(c.baseURL + "/searches/common_search?" + query.Encode())
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", errSiteUnavailable, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("%w: lecturer search: unexpected status %s", errSiteUnavailable, resp.Status)
+	}
+
+	page := strings.TrimSuffix(resp.Request.URL.Path, "/")
+	if !strings.Contains(page, "/lecturers/") {
+		return "", "", fmt.Errorf("%w: %q did not resolve to a single lecturer", errLecturerNotFound, teacher)
+	}
+	return c.baseURL + page + ".ics", LECTURER_PREFIX + path.Base(page), nil
+}
+
+func (c *scheduleCache) lecturer(teacher string) (lecturerLink, bool, error) {
+
+	// Функция lecturer() возвращает страницу преподавателя. Найденные страницы запоминаются в кеше,
+	// поэтому сайт ищет преподавателя только при первом запросе. Второй результат - взята ли страница из кеша.
+
+	key := normalizeName(teacher)
+	if link, ok := c.lecturerLinks()[key]; ok {
+		return link, true, nil
+	}
+
+	link, name, err := c.lecturerURL(teacher)
+	if err != nil {
+		return lecturerLink{}, false, err
+	}
+	found := lecturerLink{URL: link, Name: name}
+	c.updateLecturerLinks(func(links map[string]lecturerLink) {
+		links[key] = found
+	})
+	return found, false, nil
+}
+
+func (c *scheduleCache) lecturerLinks() map[string]lecturerLink {
+
+	// Функция lecturerLinks() возвращает запомненные страницы преподавателей.
+
+	l := c.groupLock(LECTURER_LINKS_FILE)
+	l.Lock()
+	defer l.Unlock()
+
+	return c.readLecturerLinks()
+}
+
+func (c *scheduleCache) updateLecturerLinks(update func(links map[string]lecturerLink)) {
+
+	// Функция updateLecturerLinks() меняет запомненные страницы преподавателей и сохраняет их на диск.
+	// Если сохранить не удалось, страница будет найдена на сайте заново при следующем запросе.
+
+	l := c.groupLock(LECTURER_LINKS_FILE)
+	l.Lock()
+	defer l.Unlock()
+
+	links := c.readLecturerLinks()
+	update(links)
+
+	data, err := json.Marshal(links)
+	if err == nil {
+		err = os.MkdirAll(c.dir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(c.dir, LECTURER_LINKS_FILE), data, 0644)
+	}
+	if err != nil {
+		slog.Warn("schedule cache: save lecturer links", "err", err)
+	}
+}
+
+func (c *scheduleCache) readLecturerLinks() map[string]lecturerLink {
+
+	// Функция readLecturerLinks() читает запомненные страницы преподавателей. Если файла нет или он испорчен, список пустой.
+
+	links := make(map[string]lecturerLink)
+	if data, err := os.ReadFile(filepath.Join(c.dir, LECTURER_LINKS_FILE)); err == nil {
+		json.Unmarshal(data, &links)
+	}
+	return links
+}
+
+func (s *ScheduleService) TeacherLessons(teacher string, day time.Time) ([]groupedLesson, error) {
+
+	// Функция TeacherLessons() возвращает занятия преподавателя на день из его расписания на сайте.
+	// В календаре преподавателя номера групп записаны в DESCRIPTION, поэтому при разборе они попадают в пометки.
+
+	link, remembered, err := s.cache.lecturer(teacher)
+	if err != nil {
+		return nil, err
+	}
+	fileName, err := s.cache.getURL(link.Name, link.URL)
+	if errors.Is(err, errGroupNotFound) && remembered {
+		// Запомненная страница пропала с сайта: преподаватель ищется заново.
+		s.cache.updateLecturerLinks(func(links map[string]lecturerLink) {
+			delete(links, normalizeName(teacher))
+		})
+		return s.TeacherLessons(teacher, day)
+	}
+	if err != nil {
+		return nil, err
+	}
+	all, err := s.parseFile(link.Name, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	date := day.Format("20060102")
+	var lessons []groupedLesson
+	for _, l := range all {
+		if l.Start.Format("20060102") != date {
+			continue
+		}
+
+		tl := groupedLesson{Lesson: l}
+		tl.Notes = nil
+		for _, note := range l.Notes {
+			if groupNumberRe.MatchString(note) {
+				tl.Groups = append(tl.Groups, note)
+			} else {
+				tl.Notes = append(tl.Notes, note)
+			}
+		}
+		lessons = append(lessons, tl)
+	}
+
+	sort.SliceStable(lessons, func(i, j int) bool {
+		return lessons[i].Start.Before(lessons[j].Start)
+	})
+	return lessons, nil
+}
+
+func formTeacherMessage(teacher string, day time.Time, lessons []groupedLesson) string {
+
+	// Функция formTeacherMessage() формирует сообщение с расписанием преподавателя на день.
+
+	var message = fmt.Sprintf("Расписание преподавателя %s на %s (%s).\nВсего занятий - %d.\n\n",
+		teacher, day.Format("02.01.2006"), getRuWeekDay(day), len(lessons))
+
+	if len(lessons) == 0 {
+		message += "Занятий нет 🥳"
+	}
+
+	for _, l := range lessons {
+		message += fmt.Sprintf("📖 %s\n", formLessonTitle(l.Lesson))
+		if len(l.Groups) > 0 {
+			message += fmt.Sprintf(" 👥 Группы: %s\n", strings.Join(l.Groups, ", "))
+		}
+		message += fmt.Sprintf(" 🏠 Аудитория: %s\n", formRooms(l.Rooms))
+		if len(l.Notes) > 0 {
+			message += fmt.Sprintf(" ℹ %s\n", strings.Join(l.Notes, ", "))
+		}
+		message += fmt.Sprintf(" 🕛 Время: %s-%s\n\n", l.Start.Format("15:04"), l.End.Format("15:04"))
+	}
+	return message
+}
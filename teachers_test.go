@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHandleTeacher(t *testing.T) {
+
+	// Преподаватель ищется на сайте один раз, а найденная страница запоминается. "Не найден" отвечается только тогда,
+	// когда поиск на сайте ничего не нашел, а недоступность сайта не выдается за отсутствие преподавателя.
+
+	var mu sync.Mutex
+	search := "lecturer"
+	var searches int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		mode := search
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/searches/common_search":
+			atomic.AddInt32(&searches, 1)
+			switch mode {
+			case "lecturer":
+				http.Redirect(w, r, "/faculties/fsu/lecturers/smirnov-aa", http.StatusFound)
+			case "nobody":
+				w.Write([]byte("Ничего не найдено"))
+			default:
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+			}
+		case "/faculties/fsu/lecturers/smirnov-aa":
+			w.Write([]byte("Смирнов А.А."))
+		case "/faculties/fsu/lecturers/smirnov-aa.ics":
+			w.Write([]byte(testCalendarHeader +
+				testEvent("20230110T084500", "20230110T102000", "Информатика", "Лекция\\, 431-2", "рк 418") +
+				testCalendarFooter))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	setSearch := func(mode string) {
+		mu.Lock()
+		search = mode
+		mu.Unlock()
+	}
+
+	// В кеше есть расписание группы с Петровым, но нет ни одного занятия Смирнова и Сидорова.
+	schedule := newTestSchedule(t, map[string]string{"431-2": testCalendarHeader +
+		testEvent("20230110T104000", "20230110T121500", "Физика", "Практика\\, Петров П.П.", "рк 202") +
+		testCalendarFooter})
+	schedule.cache.baseURL = server.URL
+
+	db := newTestDB(t)
+	ms := newFakeMessenger()
+	rt := newRouter(botCommands()...)
+	chat := chatID{PLATFORM_VK, 1}
+	ask := func(text string) string {
+		before := len(ms.messages(chat.ID))
+		r := &request{chat: chat, user: chat, config: &Config{Location: scheduleLocation}, db: db, messenger: ms, schedule: schedule}
+		if !rt.dispatch(r, text) {
+			t.Fatalf("%q is not dispatched", text)
+		}
+		return strings.Join(ms.messages(chat.ID)[before:], "\n")
+	}
+
+	for i := 0; i < 2; i++ {
+		if reply := ask("препод Смирнов 10.01.2023"); !strings.Contains(reply, "Информатика") || !strings.Contains(reply, "431-2") {
+			t.Errorf("lecturer schedule: reply = %q", reply)
+		}
+	}
+	if n := atomic.LoadInt32(&searches); n != 1 {
+		t.Errorf("lecturer searched %d times, want 1", n)
+	}
+
+	// Запомненная страница используется и тогда, когда поиск на сайте недоступен.
+	setSearch("down")
+	if reply := ask("препод смирнов 10.01.2023"); !strings.Contains(reply, "Информатика") {
+		t.Errorf("remembered lecturer: reply = %q", reply)
+	}
+
+	tests := []struct {
+		search string
+		text   string
+		want   string
+	}{
+		{"nobody", "препод Сидоров 10.01.2023", fmt.Sprintf(teacherNotFoundMsg, "Сидоров")},
+		{"down", "препод Сидоров 10.01.2023", siteUnavailableMsg},
+		{"down", "препод Петров 10.01.2023", teacherCachedNote},
+		{"nobody", "препод Петров 10.01.2023", teacherCachedNote},
+	}
+	for _, tt := range tests {
+		setSearch(tt.search)
+		if reply := ask(tt.text); !strings.Contains(reply, tt.want) {
+			t.Errorf("%s, %q: reply = %q, want %q", tt.search, tt.text, reply, tt.want)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Мессенджер Telegram, работающий через Bot API методом long polling (getUpdates).
+type telegramMessenger struct {
+	apiURL string // Адрес Bot API вместе с токеном: https://api.telegram.org/bot<token>.
+	client *http.Client
+
+	// Кнопки обычной клавиатуры Telegram отправляют в чат только свою надпись,
+	// поэтому команды кнопок запоминаются по надписям при отправке клавиатуры.
+	mu      sync.Mutex
+	buttons map[string]string
+}
+
+// Время ожидания новых сообщений в одном запросе getUpdates.
+const TELEGRAM_POLL_TIMEOUT = 30 * time.Second
+
+// Сколько раз повторяется запрос, на который Bot API ответил 429 Too Many Requests.
+const TELEGRAM_MAX_RETRIES = 3
+
+// Ответ Bot API. Поле result зависит от вызванного метода.
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		From struct {
+			ID int64 `json:"id"`
+		} `json:"from"`
+		Text string `json:"text"`
+	} `json:"message"`
+	CallbackQuery *struct {
+		ID   string `json:"id"`
+		From struct {
+			ID int64 `json:"id"`
+		} `json:"from"`
+		Message *struct {
+			MessageID int64 `json:"message_id"`
+			Chat      struct {
+				ID int64 `json:"id"`
+			} `json:"chat"`
+		} `json:"message"`
+		Data string `json:"data"`
+	} `json:"callback_query"`
+}
+
+type telegramButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data,omitempty"`
+}
+
+type telegramKeyboard struct {
+	Keyboard       [][]telegramButton `json:"keyboard"`
+	ResizeKeyboard bool               `json:"resize_keyboard"`
+}
+
+type telegramInlineKeyboard struct {
+	InlineKeyboard [][]telegramButton `json:"inline_keyboard"`
+}
+
+func newTelegramMessenger(apiURL string, token string) *telegramMessenger {
+	return &telegramMessenger{
+		apiURL:  strings.TrimRight(apiURL, "/") + "/bot" + token,
+		client:  &http.Client{Timeout: TELEGRAM_POLL_TIMEOUT + 10*time.Second},
+		buttons: make(map[string]string),
+	}
+}
+
+func (m *telegramMessenger) platform() string {
+	return PLATFORM_TELEGRAM
+}
+
+func (m *telegramMessenger) call(ctx context.Context, method string, body interface{}, result interface{}) error {
+
+	// Функция call() вызывает метод Bot API с параметрами в JSON и разбирает поле result ответа.
+	// Запрос, отклоненный из-за лимита (429), повторяется после паузы retry_after.
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		response, status, err := m.post(ctx, method, data)
+		if err != nil {
+			return err
+		}
+
+		// 429 - превышен лимит сообщений, Bot API сообщает, через сколько секунд можно повторить запрос.
+		if status == http.StatusTooManyRequests && response.Parameters.RetryAfter > 0 && attempt < TELEGRAM_MAX_RETRIES {
+			slog.Warn("telegram: rate limited, retrying", "method", method, "retry_after", response.Parameters.RetryAfter)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(response.Parameters.RetryAfter) * time.Second):
+			}
+			continue
+		}
+
+		if !response.OK {
+			// 403 - бота заблокировали или удалили из группы, писать в этот чат он больше не может.
+			if status == http.StatusForbidden {
+				return fmt.Errorf("%w: telegram: %s: %s", errChatBlocked, method, response.Description)
+			}
+			return fmt.Errorf("telegram: %s: %s", method, response.Description)
+		}
+		if result != nil {
+			return json.Unmarshal(response.Result, result)
+		}
+		return nil
+	}
+}
+
+func (m *telegramMessenger) post(ctx context.Context, method string, data []byte) (telegramResponse, int, error) {
+
+	// Функция post() отправляет один запрос к Bot API и возвращает разобранный ответ вместе с HTTP-статусом.
+
+	var response telegramResponse
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.apiURL+"/"+method, bytes.NewReader(data))
+	if err != nil {
+		return response, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		// Ошибка запроса содержит адрес вместе с токеном, поэтому наружу отдается только название метода.
+		return response, 0, fmt.Errorf("telegram: %s: request failed", method)
+	}
+	defer resp.Body.Close()
+
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return response, resp.StatusCode, fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	return response, resp.StatusCode, nil
+}
+
+func (m *telegramMessenger) markup(kb *keyboard) interface{} {
+
+	// Функция markup() собирает клавиатуру Telegram. Команды кнопок встроенной клавиатуры передаются в callback_data,
+	// а обычной - запоминаются, чтобы распознать нажатие по надписи.
+
+	var rows [][]telegramButton
+	for _, row := range kb.rows {
+		var buttons []telegramButton
+		for _, b := range row {
+			if kb.inline {
+				buttons = append(buttons, telegramButton{Text: b.label, CallbackData: b.command})
+				continue
+			}
+			buttons = append(buttons, telegramButton{Text: b.label})
+			m.mu.Lock()
+			m.buttons[b.label] = b.command
+			m.mu.Unlock()
+		}
+		rows = append(rows, buttons)
+	}
+
+	if kb.inline {
+		return telegramInlineKeyboard{InlineKeyboard: rows}
+	}
+	return telegramKeyboard{Keyboard: rows, ResizeKeyboard: true}
+}
+
+func (m *telegramMessenger) send(peerID int64, message string, kb *keyboard) error {
+	body := map[string]interface{}{
+		"chat_id": peerID,
+		"text":    message,
+	}
+	if kb != nil {
+		body["reply_markup"] = m.markup(kb)
+	}
+
+	err := m.call(context.Background(), "sendMessage", body, nil)
+	if err != nil {
+		sendFailuresTotal.WithLabelValues(PLATFORM_TELEGRAM).Inc()
+	}
+	return err
+}
+
+func (m *telegramMessenger) edit(peerID int64, messageID int64, message string, kb *keyboard) error {
+	body := map[string]interface{}{
+		"chat_id":    peerID,
+		"message_id": messageID,
+		"text":       message,
+	}
+	if kb != nil {
+		body["reply_markup"] = m.markup(kb)
+	}
+	return m.call(context.Background(), "editMessageText", body, nil)
+}
+
+func (m *telegramMessenger) answer(peerID int64, userID int64, event *buttonEvent) error {
+	return m.call(context.Background(), "answerCallbackQuery", map[string]interface{}{
+		"callback_query_id": event.id,
+	}, nil)
+}
+
+func (m *telegramMessenger) run(ctx context.Context, handler func(incomingMessage)) error {
+
+	// Функция run() запрашивает новые сообщения методом getUpdates, пока не будет отменен контекст.
+	// Обработанные обновления подтверждаются через offset, а при ошибке сети запрос повторяется через несколько секунд.
+
+	var offset int64
+
+	for {
+		var updates []telegramUpdate
+		err := m.call(ctx, "getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         int(TELEGRAM_POLL_TIMEOUT.Seconds()),
+			"allowed_updates": []string{"message", "callback_query"},
+		}, &updates)
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			slog.Warn("telegram: get updates failed, retrying", "err", err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(3 * time.Second):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+
+			// Нажатие на кнопку встроенной клавиатуры под сообщением бота.
+			if q := u.CallbackQuery; q != nil && q.Message != nil && q.Data != "" {
+				handler(incomingMessage{
+					chat:  chatID{Platform: PLATFORM_TELEGRAM, ID: q.Message.Chat.ID},
+					user:  q.From.ID,
+					text:  q.Data,
+					event: &buttonEvent{id: q.ID, messageID: q.Message.MessageID},
+				})
+				continue
+			}
+
+			if u.Message == nil || u.Message.Text == "" {
+				continue
+			}
+			handler(incomingMessage{
+				chat: chatID{Platform: PLATFORM_TELEGRAM, ID: u.Message.Chat.ID},
+				user: u.Message.From.ID,
+				text: m.commandText(u.Message.Text),
+			})
+		}
+	}
+}
+
+func (m *telegramMessenger) commandText(text string) string {
+
+	// В группах Telegram добавляет к командам имя бота: "/bind@TusurScheduleBot 432-1".
+	// Имя бота убирается, чтобы команда распознавалась так же, как в ВК.
+	// Надпись кнопки клавиатуры бота заменяется командой этой кнопки.
+
+	m.mu.Lock()
+	command, ok := m.buttons[text]
+	m.mu.Unlock()
+	if ok {
+		return command
+	}
+
+	if !strings.HasPrefix(text, "/") {
+		return text
+	}
+	end := strings.IndexAny(text, " \n")
+	if end < 0 {
+		end = len(text)
+	}
+	if at := strings.IndexByte(text[:end], '@'); at >= 0 {
+		return text[:at] + text[end:]
+	}
+	return text
+}
+
+func (m *telegramMessenger) isChatAdmin(peerID int64, userID int64) (bool, error) {
+
+	// Функция isChatAdmin() проверяет статус пользователя в группе методом getChatMember.
+	// ID личных чатов в Telegram положительные, групп - отрицательные.
+
+	if peerID > 0 {
+		return peerID == userID, nil
+	}
+
+	var member struct {
+		Status string `json:"status"`
+	}
+	err := m.call(context.Background(), "getChatMember", map[string]interface{}{
+		"chat_id": peerID,
+		"user_id": userID,
+	}, &member)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", errChatAdminsUnavailable, err)
+	}
+	return member.Status == "creator" || member.Status == "administrator", nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Поддельный Bot API: запоминает вызовы и отвечает заданными функциями по названию метода.
+type fakeTelegram struct {
+	t       *testing.T
+	mu      sync.Mutex
+	calls   map[string][]map[string]interface{}
+	methods map[string]func(body map[string]interface{}, n int) (int, string)
+}
+
+func newFakeTelegram(t *testing.T) (*fakeTelegram, *telegramMessenger) {
+	t.Helper()
+
+	f := &fakeTelegram{
+		t:       t,
+		calls:   make(map[string][]map[string]interface{}),
+		methods: make(map[string]func(body map[string]interface{}, n int) (int, string)),
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, newTelegramMessenger(server.URL, "123:token")
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method, ok := strings.CutPrefix(r.URL.Path, "/bot123:token/")
+	if !ok {
+		f.t.Errorf("unexpected path %q", r.URL.Path)
+		http.NotFound(w, r)
+		return
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		f.t.Errorf("%s: bad body: %v", method, err)
+	}
+
+	f.mu.Lock()
+	f.calls[method] = append(f.calls[method], body)
+	n := len(f.calls[method])
+	handler := f.methods[method]
+	f.mu.Unlock()
+
+	status, response := http.StatusOK, `{"ok":true,"result":true}`
+	if handler != nil {
+		status, response = handler(body, n)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(response))
+}
+
+func (f *fakeTelegram) called(method string) []map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+func TestTelegramGetUpdatesOffset(t *testing.T) {
+	f, tg := newFakeTelegram(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Первый ответ - сообщение и нажатие кнопки, второй - пустой, после третьего запроса опрос останавливается.
+	f.methods["getUpdates"] = func(body map[string]interface{}, n int) (int, string) {
+		switch n {
+		case 1:
+			return http.StatusOK, `{"ok":true,"result":[
+				{"update_id":41,"message":{"chat":{"id":-100},"from":{"id":7},"text":"/bind@TusurScheduleBot 431-2"}},
+				{"update_id":42,"callback_query":{"id":"q1","from":{"id":8},"message":{"message_id":5,"chat":{"id":-100}},"data":"/settings"}}
+			]}`
+		case 2:
+			return http.StatusOK, `{"ok":true,"result":[]}`
+		}
+		cancel()
+		return http.StatusOK, `{"ok":true,"result":[]}`
+	}
+
+	var messages []incomingMessage
+	if err := tg.run(ctx, func(m incomingMessage) { messages = append(messages, m) }); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := f.called("getUpdates")
+	if len(calls) < 3 {
+		t.Fatalf("getUpdates called %d times, want 3", len(calls))
+	}
+	for i, want := range []float64{0, 43, 43} {
+		if got := calls[i]["offset"]; got != want {
+			t.Errorf("getUpdates #%d offset = %v, want %v", i+1, got, want)
+		}
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	if m := messages[0]; m.chat != (chatID{PLATFORM_TELEGRAM, -100}) || m.user != 7 || m.text != "/bind 431-2" || m.event != nil {
+		t.Errorf("message = %+v", m)
+	}
+	if m := messages[1]; m.user != 8 || m.text != "/settings" || m.event == nil || m.event.id != "q1" || m.event.messageID != 5 {
+		t.Errorf("callback = %+v", m)
+	}
+}
+
+func TestTelegramSendMessage(t *testing.T) {
+	f, tg := newFakeTelegram(t)
+
+	kb := &keyboard{rows: [][]button{{{label: "Сегодня", command: "расписос"}}}}
+	if err := tg.send(-100, "привет", kb); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := f.called("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("sendMessage called %d times, want 1", len(calls))
+	}
+	if calls[0]["chat_id"] != float64(-100) || calls[0]["text"] != "привет" {
+		t.Errorf("body = %v", calls[0])
+	}
+	markup, _ := calls[0]["reply_markup"].(map[string]interface{})
+	if markup == nil || markup["keyboard"] == nil {
+		t.Errorf("reply_markup = %v", calls[0]["reply_markup"])
+	}
+
+	// Надпись кнопки обычной клавиатуры распознается как ее команда.
+	if got := tg.commandText("Сегодня"); got != "расписос" {
+		t.Errorf("commandText = %q, want %q", got, "расписос")
+	}
+
+	f.methods["sendMessage"] = func(body map[string]interface{}, n int) (int, string) {
+		return http.StatusForbidden, `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`
+	}
+	if err := tg.send(7, "привет", nil); !errors.Is(err, errChatBlocked) {
+		t.Errorf("err = %v, want errChatBlocked", err)
+	}
+}
+
+func TestTelegramRetryAfter(t *testing.T) {
+	f, tg := newFakeTelegram(t)
+
+	f.methods["sendMessage"] = func(body map[string]interface{}, n int) (int, string) {
+		if n == 1 {
+			return http.StatusTooManyRequests, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`
+		}
+		return http.StatusOK, `{"ok":true,"result":{}}`
+	}
+
+	start := time.Now()
+	if err := tg.send(-100, "привет", nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least retry_after", elapsed)
+	}
+	if n := len(f.called("sendMessage")); n != 2 {
+		t.Errorf("sendMessage called %d times, want 2", n)
+	}
+
+	// Без retry_after повторять запрос не с чем, и ошибка 429 возвращается сразу.
+	f.methods["sendMessage"] = func(body map[string]interface{}, n int) (int, string) {
+		return http.StatusTooManyRequests, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 0","parameters":{"retry_after":0}}`
+	}
+	if err := tg.send(-100, "привет", nil); err == nil {
+		t.Error("expected error on 429 without retry_after")
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SevereCloud/vksdk/v2/api"
+	"github.com/SevereCloud/vksdk/v2/api/params"
+	"github.com/SevereCloud/vksdk/v2/events"
+	"github.com/SevereCloud/vksdk/v2/longpoll-bot"
+	"github.com/SevereCloud/vksdk/v2/object"
+	"log/slog"
+	"math/rand"
+)
+
+// Мессенджер ВКонтакте, работающий через Bots Long Poll API сообщества.
+type vkMessenger struct {
+	vk      *api.VK
+	groupID int
+}
+
+func newVKMessenger(token string) (*vkMessenger, error) {
+
+	// Подключение к API VK с помощью токена, и получение группы, от которой был получен токен.
+
+	vk := api.NewVK(token)
+	group, err := vk.GroupsGetByID(nil)
+	if err != nil {
+		return nil, fmt.Errorf("vk: %w", err)
+	}
+	if len(group) == 0 {
+		return nil, fmt.Errorf("vk: token is not a community token")
+	}
+	return &vkMessenger{vk: vk, groupID: group[0].ID}, nil
+}
+
+func (m *vkMessenger) platform() string {
+	return PLATFORM_VK
+}
+
+// Команда кнопки в ее payload. Кнопка "Начать" в личных сообщениях сообщества присылает {"command":"start"}.
+type vkPayload struct {
+	Cmd     string `json:"cmd,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+func (p vkPayload) text() string {
+	if p.Command == "start" {
+		return "/start"
+	}
+	return p.Cmd
+}
+
+func vkKeyboard(kb *keyboard) *object.MessagesKeyboard {
+
+	// Функция vkKeyboard() собирает клавиатуру ВК. Кнопки обычной клавиатуры - текстовые, встроенной - callback-кнопки,
+	// нажатие на которые приходит событием message_event. Команда кнопки передается в payload.
+
+	k := object.NewMessagesKeyboard(false)
+	if kb.inline {
+		k = object.NewMessagesKeyboardInline()
+	}
+	for _, row := range kb.rows {
+		k.AddRow()
+		for _, b := range row {
+			if kb.inline {
+				k.AddCallbackButton(b.label, vkPayload{Cmd: b.command}, "secondary")
+			} else {
+				k.AddTextButton(b.label, vkPayload{Cmd: b.command}, "secondary")
+			}
+		}
+	}
+	return k
+}
+
+func (m *vkMessenger) send(peerID int64, message string, kb *keyboard) error {
+	b := params.NewMessagesSendBuilder()
+	// ВК требует уникальный random_id для каждого сообщения, иначе одинаковые сообщения подряд могут не дойти.
+	b.RandomID(int(rand.Int31()))
+	b.PeerID(int(peerID))
+	b.Message(message)
+
+	if kb != nil {
+		b.Keyboard(vkKeyboard(kb))
+	}
+
+	_, err := m.vk.MessagesSend(b.Params)
+	if err != nil {
+		sendFailuresTotal.WithLabelValues(PLATFORM_VK).Inc()
+	}
+
+	// Ошибки, означающие, что бот больше не может писать в этот чат, отличаются от временных сбоев:
+	// повторять отправку бесполезно.
+	for _, blocked := range []api.ErrorType{api.ErrMessagesUserBlocked, api.ErrMessagesDenySend, api.ErrMessagesPrivacy,
+		api.ErrMessagesChatUserNoAccess, api.ErrMessagesChatNotExist, api.ErrMessagesChatDisabled} {
+		if errors.Is(err, blocked) {
+			return fmt.Errorf("%w: %v", errChatBlocked, err)
+		}
+	}
+	return err
+}
+
+func (m *vkMessenger) edit(peerID int64, messageID int64, message string, kb *keyboard) error {
+	b := params.NewMessagesEditBuilder()
+	b.PeerID(int(peerID))
+	b.ConversationMessageID(int(messageID))
+	b.Message(message)
+	if kb != nil {
+		b.Keyboard(vkKeyboard(kb))
+	}
+
+	_, err := m.vk.MessagesEdit(b.Params)
+	return err
+}
+
+func (m *vkMessenger) answer(peerID int64, userID int64, event *buttonEvent) error {
+	b := params.NewMessagesSendMessageEventAnswerBuilder()
+	b.EventID(event.id)
+	b.UserID(int(userID))
+	b.PeerID(int(peerID))
+
+	_, err := m.vk.MessagesSendMessageEventAnswer(b.Params)
+	return err
+}
+
+func (m *vkMessenger) run(ctx context.Context, handler func(incomingMessage)) error {
+
+	// Создание нового lonpoll'а для обработки событий
+	lp, err := longpoll.NewLongPoll(m.vk, m.groupID)
+	if err != nil {
+		return fmt.Errorf("vk: %w", err)
+	}
+
+	// Функция, обрабатывающая новое событие получения нового сообщения.
+	// Если сообщение отправлено нажатием на кнопку клавиатуры бота, вместо надписи на кнопке выполняется ее команда.
+	lp.MessageNew(func(_ context.Context, obj events.MessageNewObject) {
+		text := obj.Message.Text
+		var payload vkPayload
+		if obj.Message.Payload != "" && json.Unmarshal([]byte(obj.Message.Payload), &payload) == nil && payload.text() != "" {
+			text = payload.text()
+		}
+
+		handler(incomingMessage{
+			chat: chatID{Platform: PLATFORM_VK, ID: int64(obj.Message.PeerID)},
+			user: int64(obj.Message.FromID),
+			text: text,
+		})
+	})
+
+	// Функция, обрабатывающая нажатие на callback-кнопку встроенной клавиатуры.
+	lp.MessageEvent(func(_ context.Context, obj events.MessageEventObject) {
+		var payload vkPayload
+		if err := json.Unmarshal(obj.Payload, &payload); err != nil || payload.Cmd == "" {
+			slog.Warn("vk: unknown button payload", "payload", string(obj.Payload))
+			return
+		}
+
+		handler(incomingMessage{
+			chat:  chatID{Platform: PLATFORM_VK, ID: int64(obj.PeerID)},
+			user:  int64(obj.UserID),
+			text:  payload.Cmd,
+			event: &buttonEvent{id: obj.EventID, messageID: int64(obj.ConversationMessageID)},
+		})
+	})
+
+	// Запуск lp-хендлера
+	return lp.RunWithContext(ctx)
+}
+
+// ID бесед ВК начинаются с этого числа, меньшие peer_id - личные сообщения.
+const VK_CHAT_PEER_OFFSET = 2000000000
+
+func (m *vkMessenger) isChatAdmin(peerID int64, userID int64) (bool, error) {
+
+	// Функция isChatAdmin() проверяет права пользователя по списку участников беседы.
+	// Список участников доступен боту, только если он сам является администратором беседы.
+
+	if peerID < VK_CHAT_PEER_OFFSET {
+		return peerID == userID, nil
+	}
+
+	b := params.NewMessagesGetConversationMembersBuilder()
+	b.PeerID(int(peerID))
+	members, err := m.vk.MessagesGetConversationMembers(b.Params)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", errChatAdminsUnavailable, err)
+	}
+
+	for _, member := range members.Items {
+		if int64(member.MemberID) == userID {
+			return bool(member.IsAdmin) || bool(member.IsOwner), nil
+		}
+	}
+	return false, nil
+}